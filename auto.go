@@ -0,0 +1,174 @@
+package rbtree
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// IncomparableError is the panic value raised by trees made by NewAuto
+// when they meet values they can not order: values whose dynamic type X
+// differs from the type Y of the values already in the tree, or values of
+// a type that has no natural order, in which case X is equal to Y.
+type IncomparableError struct {
+	X, Y reflect.Type
+}
+
+func (e *IncomparableError) Error() string {
+	if e.X == e.Y {
+		return fmt.Sprintf("rbtree: values of type %v are not ordered", e.X)
+	}
+	return fmt.Sprintf("rbtree: can not compare %v with %v", e.X, e.Y)
+}
+
+// NewAuto creates a tree whose comparator is chosen by reflection from the
+// dynamic type of the first inserted value. Supported types are:
+//
+//   - types with a method Compare(T) int or Compare(interface{}) int,
+//     where T is the type itself;
+//   - booleans (false < true), integers, floats (NaN first) and strings;
+//   - arrays and structs of supported types, compared element by element
+//     or field by field in declaration order.
+//
+// Comparing a value of another type, or of an unsupported type, panics with
+// an *IncomparableError, before the tree is modified. The first value of a
+// tree isn't compared when it is inserted, so a first value of an
+// unsupported type is only rejected by the next operation comparing it,
+// until it is removed by Clean. Trees made by NewAuto are convenient for
+// prototypes, but a dedicated CompareFunc is much faster.
+func NewAuto() *Tree {
+	return New(new(autoCompare).compare)
+}
+
+type autoCompare struct {
+	typ reflect.Type
+	f   func(a, b reflect.Value) int
+}
+
+// compare checks the types of x and y before comparing them. The payload
+// in the tree, y when searching, is checked first, so that it chooses the
+// comparator.
+func (c *autoCompare) compare(x, y interface{}) int {
+	c.check(y)
+	c.check(x)
+	return c.f(reflect.ValueOf(x), reflect.ValueOf(y))
+}
+
+// check panics unless v can be compared. The comparator is chosen by the
+// first value checked.
+func (c *autoCompare) check(v interface{}) {
+	typ := reflect.TypeOf(v)
+	if c.f == nil {
+		f, err := autoCompareFunc(typ, true)
+		if err != nil {
+			panic(err)
+		}
+		c.typ, c.f = typ, f
+	}
+	if typ != c.typ {
+		panic(&IncomparableError{X: typ, Y: c.typ})
+	}
+}
+
+type comparer interface {
+	Compare(v interface{}) int
+}
+
+var comparerType = reflect.TypeOf((*comparer)(nil)).Elem()
+
+// autoCompareFunc builds a comparator for values of type t. Compare methods
+// are used only if methods is true, since they can't be called on values
+// read from unexported struct fields.
+func autoCompareFunc(t reflect.Type, methods bool) (func(a, b reflect.Value) int, error) {
+	if t == nil {
+		return nil, &IncomparableError{}
+	}
+	if methods && t.Implements(comparerType) {
+		return func(a, b reflect.Value) int {
+			return a.Interface().(comparer).Compare(b.Interface())
+		}, nil
+	}
+	if m, ok := t.MethodByName("Compare"); methods && ok && m.Type.NumIn() == 2 &&
+		m.Type.In(1) == t && m.Type.NumOut() == 1 && m.Type.Out(0).Kind() == reflect.Int {
+		return func(a, b reflect.Value) int {
+			return int(m.Func.Call([]reflect.Value{a, b})[0].Int())
+		}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(a, b reflect.Value) int {
+			x, y := a.Bool(), b.Bool()
+			if x == y {
+				return 0
+			} else if x {
+				return +1
+			}
+			return -1
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b reflect.Value) int {
+			x, y := a.Int(), b.Int()
+			if x > y {
+				return +1
+			} else if x < y {
+				return -1
+			}
+			return 0
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b reflect.Value) int {
+			x, y := a.Uint(), b.Uint()
+			if x > y {
+				return +1
+			} else if x < y {
+				return -1
+			}
+			return 0
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(a, b reflect.Value) int {
+			return compareFloat(a.Float(), b.Float(), NaNFirst)
+		}, nil
+	case reflect.String:
+		return func(a, b reflect.Value) int {
+			x, y := a.String(), b.String()
+			if x > y {
+				return +1
+			} else if x < y {
+				return -1
+			}
+			return 0
+		}, nil
+	case reflect.Array:
+		elem, err := autoCompareFunc(t.Elem(), methods)
+		if err != nil {
+			return nil, err
+		}
+		return func(a, b reflect.Value) int {
+			for i := 0; i < a.Len(); i++ {
+				if cmp := elem(a.Index(i), b.Index(i)); cmp != 0 {
+					return cmp
+				}
+			}
+			return 0
+		}, nil
+	case reflect.Struct:
+		fields := make([]func(a, b reflect.Value) int, t.NumField())
+		for i := range fields {
+			f := t.Field(i)
+			var err error
+			if fields[i], err = autoCompareFunc(f.Type, methods && f.PkgPath == ""); err != nil {
+				return nil, err
+			}
+		}
+		return func(a, b reflect.Value) int {
+			for i, f := range fields {
+				if cmp := f(a.Field(i), b.Field(i)); cmp != 0 {
+					return cmp
+				}
+			}
+			return 0
+		}, nil
+	}
+	return nil, &IncomparableError{X: t, Y: t}
+}
@@ -0,0 +1,105 @@
+package rbtree
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type version struct {
+	Major, Minor int
+	tag          string
+}
+
+type byLen string
+
+func (s byLen) Compare(other byLen) int { return len(s) - len(other) }
+
+type anyCompare struct{ v int }
+
+func (a anyCompare) Compare(other interface{}) int { return compareInt(a.v, other.(anyCompare).v) }
+
+func autoValues(t *testing.T, tr *Tree) []interface{} {
+	var vs []interface{}
+	tr.Walk(VisitFunc(func(n *Node) bool {
+		vs = append(vs, n.Value())
+		return true
+	}))
+	assert.True(t, checkRbTree(tr))
+	return vs
+}
+
+func TestAuto(t *testing.T) {
+	cases := []struct {
+		in, want []interface{}
+	}{
+		{[]interface{}{3, -1, math.MaxInt64, math.MinInt64, 3}, []interface{}{math.MinInt64, -1, 3, math.MaxInt64}},
+		{[]interface{}{uint8(200), uint8(3)}, []interface{}{uint8(3), uint8(200)}},
+		{[]interface{}{2.5, math.Inf(-1), 1.0}, []interface{}{math.Inf(-1), 1.0, 2.5}},
+		{[]interface{}{"b", "a", "c"}, []interface{}{"a", "b", "c"}},
+		{[]interface{}{true, false}, []interface{}{false, true}},
+		{[]interface{}{[2]int{1, 2}, [2]int{1, 1}, [2]int{0, 9}}, []interface{}{[2]int{0, 9}, [2]int{1, 1}, [2]int{1, 2}}},
+		{
+			[]interface{}{version{1, 2, "b"}, version{1, 2, "a"}, version{0, 9, ""}},
+			[]interface{}{version{0, 9, ""}, version{1, 2, "a"}, version{1, 2, "b"}},
+		},
+		{[]interface{}{byLen("aaa"), byLen("z"), byLen("bb")}, []interface{}{byLen("z"), byLen("bb"), byLen("aaa")}},
+		{[]interface{}{anyCompare{2}, anyCompare{1}}, []interface{}{anyCompare{1}, anyCompare{2}}},
+	}
+	for _, c := range cases {
+		tr := NewAuto()
+		for _, v := range c.in {
+			tr.Insert(v)
+		}
+		assert.Equal(t, c.want, autoValues(t, tr))
+	}
+
+	now := time.Now()
+	tr := NewAuto()
+	tr.Insert(now.Add(time.Hour))
+	tr.Insert(now)
+	assert.Equal(t, now, tr.First().Value())
+}
+
+func TestAutoIncomparable(t *testing.T) {
+	tr := NewAuto()
+	tr.Insert(1)
+	func() {
+		defer func() {
+			err, ok := recover().(*IncomparableError)
+			assert.True(t, ok)
+			assert.Equal(t, "rbtree: can not compare string with int", err.Error())
+		}()
+		tr.Insert("1")
+	}()
+	assert.Panics(t, func() { tr.Insert(int64(1)) })
+	assert.Equal(t, 1, tr.Len())
+
+	// Unsupported types are rejected when compared, which the first
+	// insertion doesn't do.
+	tr = NewAuto()
+	tr.Insert([]int{1})
+	func() {
+		defer func() {
+			err, ok := recover().(*IncomparableError)
+			assert.True(t, ok)
+			assert.Equal(t, "rbtree: values of type []int are not ordered", err.Error())
+		}()
+		tr.Insert([]int{2})
+	}()
+	assert.Panics(t, func() { tr.Has([]int{1}) })
+	assert.Equal(t, 1, tr.Len())
+
+	// A rejected value doesn't choose the comparator.
+	tr.Clean()
+	tr.Insert(2)
+	tr.Insert(1)
+	assert.Equal(t, 1, tr.First().Value())
+
+	type bad struct{ m map[int]int }
+	assert.Panics(t, func() { tr.Insert(bad{}) })
+	assert.Panics(t, func() { tr.Insert(func() {}) })
+	assert.Equal(t, 2, tr.Len())
+}
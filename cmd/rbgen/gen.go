@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+)
+
+// config describes the tree to generate.
+type config struct {
+	Package string // package name
+	Name    string // name of the tree type
+	Key     string // key type
+	Value   string // value type, or empty
+	Cmp     string // comparison function, or empty to use <
+	Args    string // command line recorded in the header
+}
+
+// Node returns the name of the node type.
+func (c *config) Node() string { return c.Name + "Node" }
+
+// lt returns an expression reporting whether a is less than b.
+func (c *config) lt(a, b string) string {
+	if c.Cmp == "" {
+		return a + " < " + b
+	}
+	return fmt.Sprintf("%s(%s, %s) < 0", c.Cmp, a, b)
+}
+
+// generate executes the template src with c and formats the result.
+func generate(c *config, src string) ([]byte, error) {
+	tmpl, err := template.New("rbgen").Funcs(template.FuncMap{"lt": c.lt}).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, c); err != nil {
+		return nil, err
+	}
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("invalid generated code: %v", err)
+	}
+	return out, nil
+}
+
+// argString quotes args for the header of generated files.
+func argString(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'") {
+			a = fmt.Sprintf("%q", a)
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,76 @@
+// Command rbgen generates a red-black tree specialized for a key type,
+// and optionally a value type. The generated code is a copy of package
+// rbtree where keys are compared inline instead of through a CompareFunc
+// and stored without being boxed in interfaces.
+//
+// Usage:
+//
+//	rbgen -key int [-value string] [-name IntTree] [-package main] [-cmp cmpFunc] [-o file] [-test file]
+//
+// Keys are compared with < unless -cmp names a function of type
+// func(a, b K) int, which must be used for types that are not ordered.
+// It's called once per level of the tree.
+// With -test, a test file is also written; it converts ints to keys and
+// therefore requires a numeric key type.
+//
+// The generated tree has a subset of the API of rbtree.Tree, in which
+// payloads are split into keys and values: Search, Has, Insert, Delete,
+// DeleteKey, PopFirst, PopLast, Clean, in-order, pre-order and post-order
+// iteration, and Walk, WalkReverse, WalkPreorder and WalkPostorder, which
+// take a func(n) bool instead of a Visitor. Other methods of rbtree.Tree,
+// such as Replace, the WalkSub family, bounds and augmented trees, are not
+// generated.
+//
+// A typical use is a go:generate directive:
+//
+//	//go:generate rbgen -key int64 -value *Item -name ItemTree -package store -o itemtree.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	var c config
+	flag.StringVar(&c.Key, "key", "", "key type (required)")
+	flag.StringVar(&c.Value, "value", "", "value type, none if empty")
+	flag.StringVar(&c.Name, "name", "Tree", "name of the tree type")
+	flag.StringVar(&c.Package, "package", "main", "package name of generated code")
+	flag.StringVar(&c.Cmp, "cmp", "", "name of a func(a, b KEY) int to compare keys, instead of <")
+	out := flag.String("o", "", "output file, standard output if empty")
+	test := flag.String("test", "", "output file of generated tests, none if empty")
+	flag.Parse()
+
+	if c.Key == "" || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	c.Args = argString(os.Args[1:])
+
+	if err := run(&c, *out, *test); err != nil {
+		fmt.Fprintln(os.Stderr, "rbgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(c *config, out, test string) error {
+	src, err := generate(c, treeTmpl)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(out, src, 0644)
+	}
+	if err != nil || test == "" {
+		return err
+	}
+	if src, err = generate(c, testTmpl); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(test, src, 0644)
+}
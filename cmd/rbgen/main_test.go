@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var cases = []struct {
+	name string
+	c    config
+	test bool
+}{
+	{"int", config{Package: "inttree", Name: "Tree", Key: "int", Args: "-key int -package inttree"}, true},
+	{"uint32_string", config{
+		Package: "maptree", Name: "Map", Key: "uint32", Value: "string",
+		Args: "-key uint32 -value string -name Map -package maptree",
+	}, true},
+	{"float_cmp", config{
+		Package: "cmptree", Name: "FloatTree", Key: "float64", Value: "[]byte", Cmp: "compareFloat",
+		Args: "-key float64 -value []byte -name FloatTree -package cmptree -cmp compareFloat",
+	}, true},
+	{"string", config{
+		Package: "strtree", Name: "StringSet", Key: "string",
+		Args: "-key string -name StringSet -package strtree",
+	}, false},
+}
+
+func TestGolden(t *testing.T) {
+	for _, c := range cases {
+		src, err := generate(&c.c, treeTmpl)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		golden := filepath.Join("testdata", c.name+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(src, want) {
+			t.Errorf("%s: generated code differs from %s; run go test -update", c.name, golden)
+		}
+	}
+}
+
+// TestCompile builds each golden file in a scratch module and runs the
+// generated test suite against it.
+func TestCompile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go invocations in short mode")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	for _, c := range cases {
+		dir, err := ioutil.TempDir("", "rbgen")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		files := map[string]string{
+			"go.mod": "module " + c.c.Package + "\n\ngo 1.16\n",
+		}
+		src, err := ioutil.ReadFile(filepath.Join("testdata", c.name+".golden"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files["tree.go"] = string(src)
+		if c.c.Cmp != "" {
+			files["cmp.go"] = "package " + c.c.Package + "\n\n" +
+				"func " + c.c.Cmp + "(a, b " + c.c.Key + ") int {\n" +
+				"\tif a < b {\n\t\treturn -1\n\t} else if a > b {\n\t\treturn 1\n\t}\n\treturn 0\n}\n"
+		}
+		if c.test {
+			test, err := generate(&c.c, testTmpl)
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			files["tree_test.go"] = string(test)
+		}
+		for name, content := range files {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		args := []string{"vet"}
+		if c.test {
+			args = []string{"test"}
+		}
+		cmd := exec.Command(gobin, append(args, "./...")...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%s: go %s: %v\n%s", c.name, args[0], err, out)
+		}
+	}
+}
@@ -0,0 +1,681 @@
+package main
+
+// treeTmpl is a specialized copy of tree.go, rb.go, iter.go and walk.go.
+const treeTmpl = `// Code generated by rbgen {{.Args}}; DO NOT EDIT.
+
+package {{.Package}}
+
+// {{.Node}} is the node in a {{.Name}}.
+type {{.Node}} struct {
+	left, right, p *{{.Node}}
+	red            bool
+	key            {{.Key}}
+{{- if .Value}}
+	value          {{.Value}}
+{{- end}}
+}
+
+// {{.Name}} is a red-black tree of {{.Key}} keys.
+type {{.Name}} struct {
+	size        int
+	root        *{{.Node}}
+	first, last *{{.Node}} // cached leftmost and rightmost nodes
+}
+
+// Left returns the left child of n.
+func (n *{{.Node}}) Left() *{{.Node}} { return n.left }
+
+// Right returns the right child of n.
+func (n *{{.Node}}) Right() *{{.Node}} { return n.right }
+
+// Parent returns the parent of n.
+func (n *{{.Node}}) Parent() *{{.Node}} { return n.p }
+
+// Key returns the key contained in n.
+func (n *{{.Node}}) Key() {{.Key}} { return n.key }
+{{- if .Value}}
+
+// Value returns the value contained in n.
+func (n *{{.Node}}) Value() {{.Value}} { return n.value }
+
+// SetValue replaces the value contained in n.
+func (n *{{.Node}}) SetValue(value {{.Value}}) { n.value = value }
+{{- end}}
+
+func (n *{{.Node}}) isRed() bool   { return n != nil && n.red }
+func (n *{{.Node}}) isBlack() bool { return n == nil || !n.red }
+
+// New{{.Name}} creates an initialized tree.
+func New{{.Name}}() *{{.Name}} {
+	return &{{.Name}}{}
+}
+
+// Root returns the root of t.
+func (t *{{.Name}}) Root() *{{.Node}} {
+	return t.root
+}
+
+// IsEmpty returns true if the tree is empty.
+func (t *{{.Name}}) IsEmpty() bool {
+	return t.size == 0
+}
+
+// Len returns size of t.
+func (t *{{.Name}}) Len() int {
+	return t.size
+}
+
+// Clean resets a tree structure to it's initial state.
+func (t *{{.Name}}) Clean() *{{.Name}} {
+	t.size = 0
+	t.root = nil
+	t.first, t.last = nil, nil
+	return t
+}
+
+// Has tests if key is already in t.
+func (t *{{.Name}}) Has(key {{.Key}}) bool {
+	return t.Search(key) != nil
+}
+
+// Search tries to find the node containing key.
+// On success, the node containing key will be returned,
+// otherwise, nil will be returned to indicate the node is not found.
+func (t *{{.Name}}) Search(key {{.Key}}) *{{.Node}} {
+	x := t.root
+	for x != nil {
+{{- if .Cmp}}
+		if c := {{.Cmp}}(key, x.key); c < 0 {
+			x = x.left
+		} else if c > 0 {
+{{- else}}
+		if key < x.key {
+			x = x.left
+		} else if x.key < key {
+{{- end}}
+			x = x.right
+		} else {
+			return x
+		}
+	}
+	return nil
+}
+
+// Insert inserts {{if .Value}}key and value{{else}}key{{end}} into correct place and returns a handle.
+// It will refuse to insert key when key is already in t, and returns the node.
+func (t *{{.Name}}) Insert(key {{.Key}}{{if .Value}}, value {{.Value}}{{end}}) (*{{.Node}}, bool) {
+	var p *{{.Node}}
+	less := false
+	x := t.root
+
+	for x != nil {
+		p = x
+{{- if .Cmp}}
+		c := {{.Cmp}}(key, x.key)
+		if less = c < 0; less {
+			x = x.left
+		} else if c > 0 {
+{{- else}}
+		if less = key < x.key; less {
+			x = x.left
+		} else if x.key < key {
+{{- end}}
+			x = x.right
+		} else {
+			// Disable duplicate key
+			return x, false
+		}
+	}
+
+	n := &{{.Node}}{p: p, red: true, key: key{{if .Value}}, value: value{{end}}}
+	if p == nil {
+		t.root = n
+		t.first, t.last = n, n
+	} else if less {
+		p.left = n
+		if p == t.first {
+			t.first = n
+		}
+	} else {
+		p.right = n
+		if p == t.last {
+			t.last = n
+		}
+	}
+	t.insertFix(n)
+	t.size++
+	return n, true
+}
+
+// DeleteKey deletes the node whose key is equal to key.
+// A boolean value is returned to indicate whether the node is found.
+func (t *{{.Name}}) DeleteKey(key {{.Key}}) bool {
+	if x := t.Search(key); x != nil {
+		t.Delete(x)
+		return true
+	}
+	return false
+}
+
+// Delete removes x from t.
+func (t *{{.Name}}) Delete(x *{{.Node}}) {
+	// z is the node that is MOVED to a new place,
+	// and red is the color of the node previously in this place.
+	var z, p *{{.Node}}
+	red := x.red
+
+	if x == t.first {
+		t.first = t.Next(x)
+	}
+	if x == t.last {
+		t.last = t.Prev(x)
+	}
+
+	if x.left == nil {
+		z, p = x.right, x.p
+		t.transplant(x, x.right)
+	} else if x.right == nil {
+		z, p = x.left, x.p
+		t.transplant(x, x.left)
+	} else {
+		// y is the minimum node on x's right subtree,
+		// it will replace x.
+		y := x.right
+		for y.left != nil {
+			y = y.left
+		}
+
+		red = y.red
+		z = y.right
+		if x.right == y {
+			p = y
+		} else {
+			t.transplant(y, y.right)
+			p = y.p
+			y.right = x.right
+			x.right.p = y
+		}
+		y.left = x.left
+		x.left.p = y
+		t.transplant(x, y)
+		y.red = x.red
+	}
+	if !red {
+		t.deleteFix(p, z)
+	}
+	t.size--
+}
+
+// PopFirst removes the node with the minimum key from t and returns it.
+// If t is empty, it returns nil.
+func (t *{{.Name}}) PopFirst() *{{.Node}} {
+	x := t.first
+	if x != nil {
+		t.Delete(x)
+	}
+	return x
+}
+
+// PopLast removes the node with the maximum key from t and returns it.
+// If t is empty, it returns nil.
+func (t *{{.Name}}) PopLast() *{{.Node}} {
+	x := t.last
+	if x != nil {
+		t.Delete(x)
+	}
+	return x
+}
+
+func (t *{{.Name}}) insertFix(x *{{.Node}}) {
+	var y *{{.Node}}
+
+	for x.p != nil && x.p.red {
+		if x.p == x.p.p.left {
+			y = x.p.p.right
+			if y.isRed() {
+				x.p.red = false
+				y.red = false
+				x.p.p.red = true
+				x = x.p.p
+			} else {
+				if x == x.p.right {
+					x = x.p
+					t.leftRotate(x)
+				}
+				x.p.red = false
+				x.p.p.red = true
+				t.rightRotate(x.p.p)
+			}
+		} else {
+			y = x.p.p.left
+			if y.isRed() {
+				x.p.red = false
+				y.red = false
+				x.p.p.red = true
+				x = x.p.p
+			} else {
+				if x == x.p.left {
+					x = x.p
+					t.rightRotate(x)
+				}
+				x.p.red = false
+				x.p.p.red = true
+				t.leftRotate(x.p.p)
+			}
+		}
+	}
+	t.root.red = false
+}
+
+// x can be nil, but it should be treated as a leaf.
+func (t *{{.Name}}) deleteFix(p, x *{{.Node}}) {
+	var y *{{.Node}}
+
+	for x != t.root && x.isBlack() {
+		if x == p.left {
+			y = p.right
+			if y.isRed() {
+				y.red = false
+				p.red = true
+				t.leftRotate(p)
+				y = p.right
+			}
+			if y.right.isBlack() && y.left.isBlack() {
+				y.red = true
+				x, p = p, p.p
+			} else {
+				if y.right.isBlack() {
+					y.left.red = false
+					y.red = true
+					t.rightRotate(y)
+					y = p.right
+				}
+				y.red = p.red
+				p.red = false
+				y.right.red = false
+				t.leftRotate(p)
+				x, p = t.root, nil
+			}
+		} else {
+			y = p.left
+			if y.isRed() {
+				y.red = false
+				p.red = true
+				t.rightRotate(p)
+				y = p.left
+			}
+			if y.left.isBlack() && y.right.isBlack() {
+				y.red = true
+				x, p = p, p.p
+			} else {
+				if y.left.isBlack() {
+					y.right.red = false
+					y.red = true
+					t.leftRotate(y)
+					y = p.left
+				}
+				y.red = p.red
+				p.red = false
+				y.left.red = false
+				t.rightRotate(p)
+				x, p = t.root, nil
+			}
+		}
+	}
+	if x != nil {
+		x.red = false
+	}
+}
+
+// transplant n to the position of pos
+func (t *{{.Name}}) transplant(pos, n *{{.Node}}) {
+	if pos.p == nil {
+		t.root = n
+	} else if pos == pos.p.left {
+		pos.p.left = n
+	} else {
+		pos.p.right = n
+	}
+	if n != nil {
+		n.p = pos.p
+	}
+}
+
+func (t *{{.Name}}) leftRotate(x *{{.Node}}) {
+	y := x.right
+	x.right = y.left
+	if y.left != nil {
+		y.left.p = x
+	}
+	t.transplant(x, y)
+	y.left = x
+	x.p = y
+}
+
+func (t *{{.Name}}) rightRotate(x *{{.Node}}) {
+	y := x.left
+	x.left = y.right
+	if y.right != nil {
+		y.right.p = x
+	}
+	t.transplant(x, y)
+	y.right = x
+	x.p = y
+}
+
+// First returns the leftmost node in t, which is the first in-order node.
+// If t is empty, it will return nil.
+func (t *{{.Name}}) First() *{{.Node}} { return t.first }
+
+// Last returns the rightmost node in t, which is the last in-order node.
+// If t is empty, it will return nil.
+func (t *{{.Name}}) Last() *{{.Node}} { return t.last }
+
+// Next looks up the successor of n. If n is the last node, it returns nil.
+func (t *{{.Name}}) Next(n *{{.Node}}) *{{.Node}} {
+	if n.right != nil {
+		x := n.right
+		for x.left != nil {
+			x = x.left
+		}
+		return x
+	}
+	x := n
+	for x.p != nil && x.p.right == x {
+		x = x.p
+	}
+	return x.p
+}
+
+// Prev looks up the presuccessor of n. If n is the first node, it returns nil.
+func (t *{{.Name}}) Prev(n *{{.Node}}) *{{.Node}} {
+	if n.left != nil {
+		x := n.left
+		for x.right != nil {
+			x = x.right
+		}
+		return x
+	}
+	x := n
+	for x.p != nil && x.p.left == x {
+		x = x.p
+	}
+	return x.p
+}
+
+// PostorderFirst looks up the first post-order node in t.
+func (t *{{.Name}}) PostorderFirst() *{{.Node}} {
+	if t.root == nil {
+		return nil
+	}
+	return t.PostorderFirstNode(t.root)
+}
+
+// PostorderNext looks up the post-order successor of n.
+func (t *{{.Name}}) PostorderNext(n *{{.Node}}) *{{.Node}} {
+	if n.p != nil && n == n.p.left && n.p.right != nil {
+		return t.PostorderFirstNode(n.p.right)
+	}
+	return n.p
+}
+
+// PostorderFirstNode looks up the first post-order node in subtree whose root is x.
+func (t *{{.Name}}) PostorderFirstNode(x *{{.Node}}) *{{.Node}} {
+	for {
+		if x.left != nil {
+			x = x.left
+		} else if x.right != nil {
+			x = x.right
+		} else {
+			return x
+		}
+	}
+}
+
+// PreorderFirst returns the first pre-order node of t, which is the root of t.
+func (t *{{.Name}}) PreorderFirst() *{{.Node}} { return t.root }
+
+// PreorderNext returns the pre-order successor of x.
+func (t *{{.Name}}) PreorderNext(x *{{.Node}}) *{{.Node}} {
+	if x.left != nil {
+		return x.left
+	} else if x.right != nil {
+		return x.right
+	}
+	for x.p != nil {
+		if x == x.p.left && x.p.right != nil {
+			return x.p.right
+		}
+		x = x.p
+	}
+	return nil
+}
+
+// PreorderLastNode looks up the last pre-order node in subtree whose root is x.
+func (t *{{.Name}}) PreorderLastNode(x *{{.Node}}) *{{.Node}} {
+	for {
+		if x.right != nil {
+			x = x.right
+		} else if x.left != nil {
+			x = x.left
+		} else {
+			return x
+		}
+	}
+}
+
+// Walk traverses t in ascending order of keys. If fn returns false, the traversal stops.
+func (t *{{.Name}}) Walk(fn func(n *{{.Node}}) bool) {
+	for x := t.First(); x != nil && fn(x); x = t.Next(x) {
+	}
+}
+
+// WalkReverse traverses t in descending order of keys.
+func (t *{{.Name}}) WalkReverse(fn func(n *{{.Node}}) bool) {
+	for x := t.Last(); x != nil && fn(x); x = t.Prev(x) {
+	}
+}
+
+// WalkPostorder traverses t in post-order, which means that a node is encountered after its children.
+func (t *{{.Name}}) WalkPostorder(fn func(n *{{.Node}}) bool) {
+	for x := t.PostorderFirst(); x != nil && fn(x); x = t.PostorderNext(x) {
+	}
+}
+
+// WalkPreorder traverses t in pre-order, which means that a node is encountered before its children.
+func (t *{{.Name}}) WalkPreorder(fn func(n *{{.Node}}) bool) {
+	for x := t.PreorderFirst(); x != nil && fn(x); x = t.PreorderNext(x) {
+	}
+}
+`
+
+// testTmpl ports the package tests to a specialized tree. It converts
+// ints to keys, so it requires a numeric key type.
+const testTmpl = `// Code generated by rbgen {{.Args}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func new{{.Name}}Node(t *{{.Name}}, i int) (*{{.Node}}, bool) {
+	return t.Insert({{.Key}}(i){{if .Value}}, *new({{.Value}}){{end}})
+}
+
+func check{{.Name}}(t *testing.T, tr *{{.Name}}) {
+	if tr.root != nil && tr.root.red {
+		t.Fatal("red root")
+	}
+	var check func(n *{{.Node}}) int
+	check = func(n *{{.Node}}) int {
+		if n == nil {
+			return 1
+		}
+		if n.red && (n.left.isRed() || n.right.isRed()) {
+			t.Fatal("red node has red child")
+		}
+		if n.left != nil && (n.left.p != n || !({{lt "n.left.key" "n.key"}})) {
+			t.Fatal("bad left child")
+		}
+		if n.right != nil && (n.right.p != n || !({{lt "n.key" "n.right.key"}})) {
+			t.Fatal("bad right child")
+		}
+		h := check(n.left)
+		if h != check(n.right) {
+			t.Fatal("unbalanced black height")
+		}
+		if !n.red {
+			h++
+		}
+		return h
+	}
+	check(tr.root)
+}
+
+func Test{{.Name}}(t *testing.T) {
+	n := 1 << 12
+	tr := New{{.Name}}()
+
+	if !tr.IsEmpty() {
+		t.Fatal("new tree is not empty")
+	}
+	for i := 0; i < n; i++ {
+		if _, ok := new{{.Name}}Node(tr, i); !ok {
+			t.Fatalf("insert %d failed", i)
+		}
+	}
+	check{{.Name}}(t, tr)
+	if tr.Len() != n || tr.Has({{.Key}}(n)) {
+		t.Fatal("bad size or content")
+	}
+	if _, ok := new{{.Name}}Node(tr, 0); ok {
+		t.Fatal("duplicate inserted")
+	}
+
+	for i := n - 1; i >= n/2; i-- {
+		tr.Delete(tr.Search({{.Key}}(i)))
+		if tr.Search({{.Key}}(i)) != nil {
+			t.Fatalf("%d not deleted", i)
+		}
+	}
+	check{{.Name}}(t, tr)
+	if tr.Len() != n-n/2 {
+		t.Fatal("bad size after delete")
+	}
+	for i := 0; i < n/2; i++ {
+		if x := tr.Search({{.Key}}(i)); x == nil || x.Key() != {{.Key}}(i) {
+			t.Fatalf("%d not found", i)
+		}
+	}
+
+	deleted := make(map[int]bool)
+	for i := 0; i < n/2; i++ {
+		random := rand.Intn(n / 2)
+		if tr.DeleteKey({{.Key}}(random)) == deleted[random] {
+			t.Fatalf("bad DeleteKey(%d)", random)
+		}
+		deleted[random] = true
+	}
+	check{{.Name}}(t, tr)
+
+	tr.Clean()
+	if tr.Len() != 0 || tr.First() != nil || tr.Last() != nil {
+		t.Fatal("bad Clean")
+	}
+}
+
+func Test{{.Name}}Iter(t *testing.T) {
+	n := 1 << 10
+	tr := New{{.Name}}()
+	if tr.PostorderFirst() != nil || tr.PreorderFirst() != nil {
+		t.Fatal("empty tree has nodes")
+	}
+	for _, i := range rand.Perm(n) {
+		new{{.Name}}Node(tr, i)
+	}
+	if tr.Prev(tr.First()) != nil || tr.Next(tr.Last()) != nil {
+		t.Fatal("bad ends")
+	}
+	for i, x := 0, tr.First(); i < n; i, x = i+1, tr.Next(x) {
+		if x == nil || x.Key() != {{.Key}}(i) {
+			t.Fatalf("bad in-order node %d", i)
+		}
+	}
+	for i, x := n-1, tr.Last(); i >= 0; i, x = i-1, tr.Prev(x) {
+		if x == nil || x.Key() != {{.Key}}(i) {
+			t.Fatalf("bad reverse node %d", i)
+		}
+	}
+
+	size := 0
+	for x := tr.PostorderFirst(); x != nil; x = tr.PostorderNext(x) {
+		size++
+	}
+	for x := tr.PreorderFirst(); x != nil; x = tr.PreorderNext(x) {
+		size++
+	}
+	if size != 2*n {
+		t.Fatalf("pre- and post-order visited %d nodes", size)
+	}
+
+	for i := 0; i < n; i++ {
+		x := tr.PopFirst()
+		if x == nil || x.Key() != {{.Key}}(i) {
+			t.Fatalf("bad PopFirst %d", i)
+		}
+	}
+	if tr.PopFirst() != nil || tr.PopLast() != nil {
+		t.Fatal("pop from empty tree")
+	}
+}
+
+func Test{{.Name}}Walk(t *testing.T) {
+	n := 1 << 10
+	tr := New{{.Name}}()
+	for i := 0; i < n; i++ {
+		new{{.Name}}Node(tr, i)
+	}
+
+	i := 0
+	tr.Walk(func(x *{{.Node}}) bool {
+		if x.Key() != {{.Key}}(i) {
+			t.Fatalf("Walk: got %v, want %v", x.Key(), i)
+		}
+		i++
+		return true
+	})
+	tr.WalkReverse(func(x *{{.Node}}) bool {
+		i--
+		if x.Key() != {{.Key}}(i) {
+			t.Fatalf("WalkReverse: got %v, want %v", x.Key(), i)
+		}
+		return true
+	})
+
+	size := 0
+	count := func(x *{{.Node}}) bool {
+		size++
+		return true
+	}
+	tr.WalkPreorder(count)
+	tr.WalkPostorder(count)
+	if size != 2*n {
+		t.Fatalf("walks visited %d nodes", size)
+	}
+
+	size = 0
+	stop := func(x *{{.Node}}) bool {
+		size++
+		return false
+	}
+	tr.Walk(stop)
+	tr.WalkReverse(stop)
+	tr.WalkPreorder(stop)
+	tr.WalkPostorder(stop)
+	if size != 4 {
+		t.Fatalf("stopped walks visited %d nodes", size)
+	}
+}
+`
@@ -0,0 +1,20 @@
+// Package collatecmp provides comparators ordering strings by the rules of
+// a language, for trees of package rbtree. It's kept out of package rbtree,
+// which doesn't depend on golang.org/x/text.
+package collatecmp
+
+import (
+	"github.com/fanyang01/rbtree"
+	"golang.org/x/text/collate"
+)
+
+// Collate returns a comparator for strings ordered by the collator c,
+// e.g. collate.New(language.German). Collators are not safe for concurrent
+// use, and neither is the result.
+func Collate(c *collate.Collator) rbtree.CompareFunc {
+	return func(x, y interface{}) int {
+		a := *(*string)(rbtree.ValuePtr(x))
+		b := *(*string)(rbtree.ValuePtr(y))
+		return c.CompareString(a, b)
+	}
+}
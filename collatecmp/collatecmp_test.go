@@ -0,0 +1,16 @@
+package collatecmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+func TestCollate(t *testing.T) {
+	c := Collate(collate.New(language.English))
+	assert.Equal(t, -1, c("a", "B"))
+	assert.Equal(t, -1, c("résumé", "rf"))
+	assert.Equal(t, 0, c("a", "a"))
+}
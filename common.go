@@ -13,6 +13,7 @@ Under current language spec, there are following patterns to implement generic c
 	func Compare(x, y interface{}) int
 
 3) using `go generate` to generate code for specific type.
+Command rbgen in cmd/rbgen generates such a specialized copy of this package.
 
 This package uses callbacks. Using tricks to get pointer of empty interface
 values can avoid data copying and runtime assertions, therefore greatly improve
@@ -28,7 +29,7 @@ import "unsafe"
 // < 0 if x is less than y
 type CompareFunc func(x, y interface{}) int
 
-// These functions are provided for convinence
+// These functions are provided for convinence. More are in compare.go.
 var (
 	CompareInt    CompareFunc = compareInt
 	CompareString             = compareString
@@ -47,7 +48,13 @@ func ValuePtr(v interface{}) unsafe.Pointer {
 func compareInt(x, y interface{}) int {
 	a := *(*int)(ValuePtr(x))
 	b := *(*int)(ValuePtr(y))
-	return a - b
+	// Subtraction overflows for operands of large magnitude.
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
 }
 
 func compareString(x, y interface{}) int {
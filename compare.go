@@ -0,0 +1,278 @@
+package rbtree
+
+import (
+	"bytes"
+	"math"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Comparators for other built-in types. Like CompareInt and CompareString,
+// they read values through ValuePtr, so the dynamic type of both arguments
+// must match exactly.
+var (
+	CompareInt8    CompareFunc = compareInt8
+	CompareInt16   CompareFunc = compareInt16
+	CompareInt32   CompareFunc = compareInt32
+	CompareInt64   CompareFunc = compareInt64
+	CompareUint    CompareFunc = compareUint
+	CompareUint8   CompareFunc = compareUint8
+	CompareUint16  CompareFunc = compareUint16
+	CompareUint32  CompareFunc = compareUint32
+	CompareUint64  CompareFunc = compareUint64
+	CompareUintptr CompareFunc = compareUintptr
+	CompareFloat32             = Float32Comparator(NaNFirst)
+	CompareFloat64             = Float64Comparator(NaNFirst)
+	CompareBytes   CompareFunc = compareBytes
+	CompareTime    CompareFunc = compareTime
+	// CompareStringFold compares strings under Unicode simple case folding,
+	// so that it agrees with strings.EqualFold.
+	CompareStringFold CompareFunc = compareStringFold
+)
+
+func compareInt8(x, y interface{}) int {
+	a := *(*int8)(ValuePtr(x))
+	b := *(*int8)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareInt16(x, y interface{}) int {
+	a := *(*int16)(ValuePtr(x))
+	b := *(*int16)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareInt32(x, y interface{}) int {
+	a := *(*int32)(ValuePtr(x))
+	b := *(*int32)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareInt64(x, y interface{}) int {
+	a := *(*int64)(ValuePtr(x))
+	b := *(*int64)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareUint(x, y interface{}) int {
+	a := *(*uint)(ValuePtr(x))
+	b := *(*uint)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareUint8(x, y interface{}) int {
+	a := *(*uint8)(ValuePtr(x))
+	b := *(*uint8)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareUint16(x, y interface{}) int {
+	a := *(*uint16)(ValuePtr(x))
+	b := *(*uint16)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareUint32(x, y interface{}) int {
+	a := *(*uint32)(ValuePtr(x))
+	b := *(*uint32)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareUint64(x, y interface{}) int {
+	a := *(*uint64)(ValuePtr(x))
+	b := *(*uint64)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+func compareUintptr(x, y interface{}) int {
+	a := *(*uintptr)(ValuePtr(x))
+	b := *(*uintptr)(ValuePtr(y))
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	}
+	return 0
+}
+
+// NaNPolicy decides where float comparators order NaN.
+// All NaNs are equal to each other, and -0 is equal to +0.
+type NaNPolicy int
+
+const (
+	// NaNFirst orders NaN before any other value, as sort.Float64s does.
+	NaNFirst NaNPolicy = iota
+	// NaNLast orders NaN after any other value.
+	NaNLast
+	// NaNPanic panics when NaN is compared.
+	NaNPanic
+)
+
+// Float32Comparator returns a comparator for float32 values using policy p.
+func Float32Comparator(p NaNPolicy) CompareFunc {
+	return func(x, y interface{}) int {
+		a := *(*float32)(ValuePtr(x))
+		b := *(*float32)(ValuePtr(y))
+		return compareFloat(float64(a), float64(b), p)
+	}
+}
+
+// Float64Comparator returns a comparator for float64 values using policy p.
+func Float64Comparator(p NaNPolicy) CompareFunc {
+	return func(x, y interface{}) int {
+		a := *(*float64)(ValuePtr(x))
+		b := *(*float64)(ValuePtr(y))
+		return compareFloat(a, b, p)
+	}
+}
+
+func compareFloat(a, b float64, p NaNPolicy) int {
+	if a > b {
+		return +1
+	} else if a < b {
+		return -1
+	} else if a == b {
+		return 0
+	}
+	// At least one of them is NaN.
+	na, nb := math.IsNaN(a), math.IsNaN(b)
+	if p == NaNPanic {
+		panic("rbtree: NaN compared")
+	}
+	cmp := 0
+	if na && !nb {
+		cmp = -1
+	} else if !na && nb {
+		cmp = +1
+	}
+	if p == NaNLast {
+		cmp = -cmp
+	}
+	return cmp
+}
+
+func compareBytes(x, y interface{}) int {
+	a := *(*[]byte)(ValuePtr(x))
+	b := *(*[]byte)(ValuePtr(y))
+	return bytes.Compare(a, b)
+}
+
+func compareTime(x, y interface{}) int {
+	a := (*time.Time)(ValuePtr(x))
+	b := (*time.Time)(ValuePtr(y))
+	if a.After(*b) {
+		return +1
+	} else if a.Before(*b) {
+		return -1
+	}
+	return 0
+}
+
+func compareStringFold(x, y interface{}) int {
+	a := *(*string)(ValuePtr(x))
+	b := *(*string)(ValuePtr(y))
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		a, b = a[na:], b[nb:]
+		if ra == rb {
+			continue
+		}
+		if ra, rb = foldRune(ra), foldRune(rb); ra > rb {
+			return +1
+		} else if ra < rb {
+			return -1
+		}
+	}
+	if a != "" {
+		return +1
+	} else if b != "" {
+		return -1
+	}
+	return 0
+}
+
+// foldRune returns the smallest rune in the case folding orbit of r,
+// which is the same for all runes equal under simple folding.
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// Reverse returns a comparator ordering values in the reverse order of f.
+func Reverse(f CompareFunc) CompareFunc {
+	return func(x, y interface{}) int { return f(y, x) }
+}
+
+// ByKey returns a comparator ordering values by the keys extracted from
+// them, which are compared by f.
+func ByKey(extract func(v interface{}) interface{}, f CompareFunc) CompareFunc {
+	return func(x, y interface{}) int { return f(extract(x), extract(y)) }
+}
+
+// Then returns a comparator for lexicographic order: values are ordered by
+// the first comparator, ties are broken by the second one, and so on.
+func Then(f CompareFunc, more ...CompareFunc) CompareFunc {
+	return func(x, y interface{}) int {
+		if cmp := f(x, y); cmp != 0 {
+			return cmp
+		}
+		for _, g := range more {
+			if cmp := g(x, y); cmp != 0 {
+				return cmp
+			}
+		}
+		return 0
+	}
+}
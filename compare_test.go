@@ -0,0 +1,130 @@
+package rbtree
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(x int) int {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+func TestCompareIntOverflow(t *testing.T) {
+	assert.Equal(t, -1, sign(CompareInt(math.MinInt64, 1)))
+	assert.Equal(t, 1, sign(CompareInt(math.MaxInt64, -1)))
+	assert.Equal(t, 0, CompareInt(math.MinInt64, math.MinInt64))
+}
+
+func TestCompareIntegers(t *testing.T) {
+	cases := []struct {
+		f           CompareFunc
+		lo, mid, hi interface{}
+	}{
+		{CompareInt8, int8(math.MinInt8), int8(0), int8(math.MaxInt8)},
+		{CompareInt16, int16(math.MinInt16), int16(0), int16(math.MaxInt16)},
+		{CompareInt32, int32(math.MinInt32), int32(0), int32(math.MaxInt32)},
+		{CompareInt64, int64(math.MinInt64), int64(0), int64(math.MaxInt64)},
+		{CompareUint, uint(0), uint(1), uint(math.MaxUint64)},
+		{CompareUint8, uint8(0), uint8(1), uint8(math.MaxUint8)},
+		{CompareUint16, uint16(0), uint16(1), uint16(math.MaxUint16)},
+		{CompareUint32, uint32(0), uint32(1), uint32(math.MaxUint32)},
+		{CompareUint64, uint64(0), uint64(1), uint64(math.MaxUint64)},
+		{CompareUintptr, uintptr(0), uintptr(1), ^uintptr(0)},
+	}
+	for _, c := range cases {
+		assert.Equal(t, -1, sign(c.f(c.lo, c.hi)))
+		assert.Equal(t, -1, sign(c.f(c.lo, c.mid)))
+		assert.Equal(t, -1, sign(c.f(c.mid, c.hi)))
+		assert.Equal(t, 1, sign(c.f(c.hi, c.lo)))
+		assert.Equal(t, 0, c.f(c.mid, c.mid))
+	}
+}
+
+func TestCompareFloat(t *testing.T) {
+	nan := math.NaN()
+	assert.Equal(t, -1, CompareFloat64(nan, math.Inf(-1)))
+	assert.Equal(t, 1, CompareFloat64(0.0, nan))
+	assert.Equal(t, 0, CompareFloat64(nan, nan))
+	assert.Equal(t, 0, CompareFloat64(math.Copysign(0, -1), 0.0))
+	assert.Equal(t, -1, CompareFloat64(1.5, 2.5))
+
+	last := Float64Comparator(NaNLast)
+	assert.Equal(t, 1, last(nan, math.Inf(1)))
+	assert.Equal(t, -1, last(0.0, nan))
+	assert.Equal(t, 0, last(nan, nan))
+
+	assert.Equal(t, -1, CompareFloat32(float32(nan), float32(-1)))
+	assert.Equal(t, 1, CompareFloat32(float32(2), float32(1)))
+
+	assert.Panics(t, func() { Float64Comparator(NaNPanic)(nan, 1.0) })
+	assert.NotPanics(t, func() { Float64Comparator(NaNPanic)(0.0, 1.0) })
+
+	tr := New(CompareFloat64)
+	for _, v := range []float64{3, nan, -1, math.Inf(1), nan} {
+		tr.Insert(v)
+	}
+	assert.Equal(t, 4, tr.Len())
+	assert.True(t, math.IsNaN(tr.First().Value().(float64)))
+}
+
+func TestCompareMisc(t *testing.T) {
+	assert.Equal(t, -1, CompareBytes([]byte("ab"), []byte("b")))
+	assert.Equal(t, 0, CompareBytes([]byte(nil), []byte{}))
+
+	now := time.Now()
+	assert.Equal(t, -1, CompareTime(now, now.Add(time.Nanosecond)))
+	assert.Equal(t, 0, CompareTime(now, now.UTC()))
+
+	assert.Equal(t, 0, CompareStringFold("Hello", "hELLO"))
+	assert.Equal(t, 0, CompareStringFold("straße", "STRAßE"))
+	assert.Equal(t, -1, sign(CompareStringFold("apple", "Banana")))
+	assert.Equal(t, 1, sign(CompareStringFold("apples", "APPLE")))
+	assert.Equal(t, 0, CompareStringFold("K", "K")) // Kelvin sign
+}
+
+func TestCombinators(t *testing.T) {
+	rev := Reverse(CompareInt)
+	assert.Equal(t, 1, rev(1, 2))
+	assert.Equal(t, 0, rev(2, 2))
+
+	type person struct {
+		last, first string
+		age         int
+	}
+	byLast := ByKey(func(v interface{}) interface{} { return v.(person).last }, CompareString)
+	byFirst := ByKey(func(v interface{}) interface{} { return v.(person).first }, CompareString)
+	byAge := ByKey(func(v interface{}) interface{} { return v.(person).age }, CompareInt)
+	f := Then(byLast, byFirst, Reverse(byAge))
+
+	tr := New(f)
+	people := []person{
+		{"Smith", "John", 30},
+		{"Doe", "Jane", 25},
+		{"Smith", "Anna", 40},
+		{"Smith", "John", 50},
+		{"Doe", "Jane", 25},
+	}
+	for _, p := range people {
+		tr.Insert(p)
+	}
+	assert.Equal(t, 4, tr.Len())
+	var got []person
+	tr.Walk(VisitFunc(func(n *Node) bool {
+		got = append(got, n.Value().(person))
+		return true
+	}))
+	assert.Equal(t, []person{
+		{"Doe", "Jane", 25},
+		{"Smith", "Anna", 40},
+		{"Smith", "John", 50},
+		{"Smith", "John", 30},
+	}, got)
+}
@@ -0,0 +1,146 @@
+package rbtree
+
+import "math/bits"
+
+// DiffKind is the kind of a Difference.
+type DiffKind int
+
+// Kinds of differences
+const (
+	Added   DiffKind = iota // a payload only in the new tree
+	Removed                 // a payload only in the old tree
+	Changed                 // equally ordered payloads that are not equal
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Difference is a difference between an old and a new tree. Old is nil for
+// Added payloads, and New is nil for Removed payloads.
+type Difference struct {
+	Kind     DiffKind
+	Old, New interface{}
+}
+
+// DiffIterator is an ordered stream of differences. Neither tree may be
+// modified during iteration.
+type DiffIterator struct {
+	a, b   *Tree
+	equal  func(x, y interface{}) bool
+	x, y   *Node
+	merkle bool
+
+	// Attempts to skip subtrees are suspended for wait steps after one
+	// fails, which doubles up to maxWait after each failure.
+	wait, backoff, maxWait int
+}
+
+// Diff returns the differences from the old tree a to the new tree b, in
+// ascend order of payloads, by walking both trees side by side. Both trees
+// must be ordered by the same CompareFunc. Payloads present in both trees
+// are reported as Changed if equal returns false for them; if equal is nil,
+// they are never reported.
+//
+// Without Merkle hashes, Diff takes O(len(a) + len(b)) time. The trees
+// are independent: they share no structure, even if one was copied from
+// the other.
+//
+// If both trees are Merkle trees using the same HashFunc, Diff tries to
+// skip whole subtrees of a whose hash is equal to the hash of the same
+// range of b. Payloads that hash equally are then considered unchanged.
+// Each attempt costs O(log n) time. After an attempt fails, the next ones
+// are put off for a number of steps that doubles with each failure, up to
+// about log n steps, so that trees differing throughout are still compared
+// in O(len(a) + len(b)) time. Trees with few differences, d say, are
+// compared in about O((d+1) log² n) time.
+func Diff(a, b *Tree, equal func(x, y interface{}) bool) *DiffIterator {
+	d := &DiffIterator{a: a, b: b, equal: equal}
+	if a != b {
+		d.x, d.y = a.First(), b.First()
+		d.merkle = a.hash != nil && b.hash != nil
+		d.maxWait = bits.Len(uint(a.size))
+	}
+	return d
+}
+
+// Next returns the next difference, and false if there are no more.
+func (d *DiffIterator) Next() (Difference, bool) {
+	for d.x != nil || d.y != nil {
+		var cmp int
+		if d.x == nil {
+			cmp = 1
+		} else if d.y == nil {
+			cmp = -1
+		} else {
+			cmp = d.a.compare(d.x.v, d.y.v)
+		}
+
+		if cmp < 0 {
+			x := d.x
+			d.x = d.a.Next(x)
+			return Difference{Kind: Removed, Old: x.v}, true
+		} else if cmp > 0 {
+			y := d.y
+			d.y = d.b.Next(y)
+			return Difference{Kind: Added, New: y.v}, true
+		}
+		if d.merkle && d.skip() {
+			continue
+		}
+		x, y := d.x, d.y
+		d.x, d.y = d.a.Next(x), d.b.Next(y)
+		if d.equal != nil && !d.equal(x.v, y.v) {
+			return Difference{Kind: Changed, Old: x.v, New: y.v}, true
+		}
+	}
+	return Difference{}, false
+}
+
+// skip tries to skip the largest subtree of a starting at d.x, along with
+// the same range of b, if their hashes are equal.
+func (d *DiffIterator) skip() bool {
+	if d.wait > 0 {
+		d.wait--
+		return false
+	}
+	u := d.x
+	if u.left != nil {
+		// Nodes before d.x are in its subtree.
+		return false
+	}
+	for u.p != nil && u.p.left == u {
+		u = u.p
+	}
+	if u == d.x && u.right == nil {
+		return false
+	}
+	end := d.a.Next(rightmost(u))
+	var hi interface{}
+	if end != nil {
+		hi = end.v
+	}
+	if u.merkle().sum != d.b.RangeHash(d.x.v, hi) {
+		if d.backoff = 2*d.backoff + 1; d.backoff > d.maxWait {
+			d.backoff = d.maxWait
+		}
+		d.wait = d.backoff
+		return false
+	}
+	d.backoff = 0
+	d.x = end
+	if end == nil {
+		d.y = nil
+	} else {
+		d.y = d.b.LowerBound(end.v)
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectDiff(it *DiffIterator) []Difference {
+	var ds []Difference
+	for d, ok := it.Next(); ok; d, ok = it.Next() {
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+func TestDiff(t *testing.T) {
+	a, b := New(comparePair), New(comparePair)
+	for _, k := range []int{1, 2, 3, 5, 8} {
+		a.Insert(&pair{k, 0})
+	}
+	for _, k := range []int{0, 2, 3, 5, 9} {
+		b.Insert(&pair{k, 0})
+	}
+	b.Replace(b.Search(&pair{key: 3}), &pair{3, 1})
+
+	equal := func(x, y interface{}) bool { return *x.(*pair) == *y.(*pair) }
+	assert.Equal(t, []Difference{
+		{Added, nil, &pair{0, 0}},
+		{Removed, &pair{1, 0}, nil},
+		{Changed, &pair{3, 0}, &pair{3, 1}},
+		{Removed, &pair{8, 0}, nil},
+		{Added, nil, &pair{9, 0}},
+	}, collectDiff(Diff(a, b, equal)))
+
+	// Without equal, only membership is compared.
+	assert.Len(t, collectDiff(Diff(a, b, nil)), 4)
+	assert.Len(t, collectDiff(Diff(a, a, equal)), 0)
+	assert.Len(t, collectDiff(Diff(New(CompareInt), New(CompareInt), nil)), 0)
+
+	e := New(comparePair)
+	ds := collectDiff(Diff(a, e, equal))
+	assert.Len(t, ds, a.Len())
+	for _, d := range ds {
+		assert.Equal(t, Removed, d.Kind)
+	}
+	assert.Equal(t, "changed", Changed.String())
+}
+
+func TestDiffMerkle(t *testing.T) {
+	n := 1 << 16
+	hash := func(v interface{}) uint64 {
+		p := v.(*pair)
+		return uint64(p.key)<<32 | uint64(p.count)
+	}
+	a, b := NewMerkle(comparePair, hash), NewMerkle(comparePair, hash)
+	pa, pb := New(comparePair), New(comparePair)
+	for i := 0; i < n; i++ {
+		a.Insert(&pair{i, 0})
+		pa.Insert(&pair{i, 0})
+	}
+	for _, i := range r.Perm(n) {
+		b.Insert(&pair{i, 0})
+		pb.Insert(&pair{i, 0})
+	}
+	for _, tr := range []*Tree{b, pb} {
+		tr.DeleteValue(&pair{key: 10})
+		tr.Insert(&pair{n, 0})
+		tr.Replace(tr.Search(&pair{key: n / 2}), &pair{n / 2, 7})
+	}
+
+	equal := func(x, y interface{}) bool { return *x.(*pair) == *y.(*pair) }
+	want := []Difference{
+		{Removed, &pair{10, 0}, nil},
+		{Changed, &pair{n / 2, 0}, &pair{n / 2, 7}},
+		{Added, nil, &pair{n, 0}},
+	}
+	assert.Equal(t, want, collectDiff(Diff(pa, pb, equal)))
+
+	// The Merkle fast path gives the same result with fewer comparisons.
+	c := new(Counters)
+	a.SetObserver(c)
+	b.SetObserver(c)
+	assert.Equal(t, want, collectDiff(Diff(a, b, equal)))
+	assert.True(t, c.Count(OpCompare) < uint64(n/4), "%d comparisons", c.Count(OpCompare))
+}
+
+// dissimilarMerkle returns Merkle trees of the same n keys, whose payloads
+// all differ.
+func dissimilarMerkle(n int) (a, b *Tree) {
+	hash := func(v interface{}) uint64 {
+		p := v.(*pair)
+		return uint64(p.key)<<32 | uint64(p.count)
+	}
+	a, b = NewMerkle(comparePair, hash), NewMerkle(comparePair, hash)
+	for i := 0; i < n; i++ {
+		a.Insert(&pair{i, 0})
+		b.Insert(&pair{i, 1})
+	}
+	return a, b
+}
+
+func TestDiffDissimilar(t *testing.T) {
+	n := 1 << 12
+	a, b := dissimilarMerkle(n)
+	c := new(Counters)
+	a.SetObserver(c)
+	b.SetObserver(c)
+	assert.Len(t, collectDiff(Diff(a, b, func(x, y interface{}) bool { return false })), n)
+	// Failed attempts to skip subtrees don't cost O(log n) per node.
+	assert.True(t, c.Count(OpCompare) < uint64(4*n), "%d comparisons", c.Count(OpCompare))
+}
+
+func BenchmarkDiffDissimilar(b *testing.B) {
+	x, y := dissimilarMerkle(1 << 14)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for it := Diff(x, y, nil); ; {
+			if _, ok := it.Next(); !ok {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,503 @@
+// Package durable keeps an rbtree.Tree on disk. Every modification is
+// appended to a log file before it is applied in memory, and a sorted
+// snapshot of the tree is written from time to time, after which the log
+// is truncated. Opening a tree replays the snapshot and the log.
+//
+// Records carry CRC-32C checksums of their lengths and of their contents.
+// A torn record at the end of the log, as left by a crash during an append,
+// is discarded: a record whose header is cut short, or whose header is
+// valid but whose contents are cut short or damaged up to the end of the
+// log. Any other damage makes Open fail with ErrCorrupt, leaving the files
+// as they are.
+//
+// A directory must not be used by more than one Tree at a time, in the same
+// or another process. Nothing enforces this.
+package durable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/fanyang01/rbtree"
+)
+
+// Codec converts payloads to bytes and back.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// Options configures a Tree. The zero value is usable.
+type Options struct {
+	// SnapshotEvery is the number of log records after which a snapshot
+	// is written. If it is 0, DefaultSnapshotEvery is used; if it is
+	// negative, snapshots are only written by Snapshot. An error of an
+	// automatic snapshot is returned by the modification triggering it,
+	// which has been logged and applied nonetheless.
+	SnapshotEvery int
+	// Sync makes every modification wait for the log to reach stable
+	// storage. Otherwise, if the machine crashes, records written since
+	// the last Snapshot or Close may be lost, and as the file system may
+	// write them back in any order, the log may also be left damaged
+	// before its last record, which makes Open fail with ErrCorrupt.
+	Sync bool
+}
+
+// DefaultSnapshotEvery is the default of Options.SnapshotEvery.
+const DefaultSnapshotEvery = 1 << 16
+
+const (
+	logName      = "log"
+	snapshotName = "snapshot"
+	tmpName      = "snapshot.tmp"
+
+	snapshotMagic = "RBTS0001"
+	headerSize    = 12 // length and checksums of a record
+)
+
+// Operations of log records. They are part of the file format, so their
+// values must never change.
+const (
+	opInsert  byte = 1
+	opDelete  byte = 2
+	opReplace byte = 3
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupt is returned by Open if the snapshot is damaged, or if the log
+// is damaged other than at its end.
+var ErrCorrupt = errors.New("durable: corrupt snapshot or log")
+
+// ErrClosed is returned by operations on a closed Tree.
+var ErrClosed = errors.New("durable: tree is closed")
+
+// Tree is a crash-safe tree stored in a directory.
+// It is not safe for concurrent use.
+type Tree struct {
+	t     *rbtree.Tree
+	codec Codec
+	opts  Options
+	dir   string
+	log   *os.File
+	w     *bufio.Writer
+	seq   uint64 // sequence number of the last record
+	n     int    // records in the log
+	off   int64  // length of the records in the log
+}
+
+// Open opens the tree stored in dir, creating dir if needed. Payloads are
+// ordered by f, which must be the same every time the tree is opened.
+// If opts is nil, default options are used.
+func Open(dir string, f rbtree.CompareFunc, codec Codec, opts *Options) (*Tree, error) {
+	d := &Tree{t: rbtree.New(f), codec: codec, dir: dir}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.SnapshotEvery == 0 {
+		d.opts.SnapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := d.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := d.replay(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Tree returns the tree in memory, which is only for reading: modifying
+// it directly bypasses the log.
+func (d *Tree) Tree() *rbtree.Tree { return d.t }
+
+// Len returns the number of payloads in the tree.
+func (d *Tree) Len() int { return d.t.Len() }
+
+// Search tries to find the node containing payload v.
+func (d *Tree) Search(v interface{}) *rbtree.Node { return d.t.Search(v) }
+
+// Has tests if v is in the tree.
+func (d *Tree) Has(v interface{}) bool { return d.t.Has(v) }
+
+// Insert logs and inserts v, like rbtree.Tree.Insert.
+func (d *Tree) Insert(v interface{}) (*rbtree.Node, bool, error) {
+	if x := d.t.Search(v); x != nil {
+		return x, false, nil
+	}
+	if err := d.append(opInsert, v); err != nil {
+		return nil, false, err
+	}
+	n, ok := d.t.Insert(v)
+	return n, ok, d.maybeSnapshot()
+}
+
+// Delete logs the deletion of x and removes it, like rbtree.Tree.Delete.
+func (d *Tree) Delete(x *rbtree.Node) (interface{}, error) {
+	if err := d.append(opDelete, x.Value()); err != nil {
+		return nil, err
+	}
+	return d.t.Delete(x), d.maybeSnapshot()
+}
+
+// DeleteValue logs the deletion of v and removes it, like
+// rbtree.Tree.DeleteValue.
+func (d *Tree) DeleteValue(v interface{}) (interface{}, bool, error) {
+	x := d.t.Search(v)
+	if x == nil {
+		return nil, false, nil
+	}
+	if err := d.append(opDelete, x.Value()); err != nil {
+		return nil, false, err
+	}
+	return d.t.Delete(x), true, d.maybeSnapshot()
+}
+
+// Replace logs and replaces the payload of n, like rbtree.Tree.Replace.
+func (d *Tree) Replace(n *rbtree.Node, v interface{}) (interface{}, bool, error) {
+	if d.t.Search(v) != n {
+		return n.Value(), false, nil
+	}
+	if err := d.append(opReplace, v); err != nil {
+		return nil, false, err
+	}
+	old, ok := d.t.Replace(n, v)
+	return old, ok, d.maybeSnapshot()
+}
+
+// Close flushes the log and closes the tree.
+func (d *Tree) Close() error {
+	if d.log == nil {
+		return ErrClosed
+	}
+	err := d.flush(true)
+	if cerr := d.log.Close(); err == nil {
+		err = cerr
+	}
+	d.log, d.w = nil, nil
+	return err
+}
+
+// append writes a record for op on v to the log. If it fails, the record
+// is removed from the log, so that it isn't applied by the next Open. If
+// it can't be removed, the tree is closed.
+func (d *Tree) append(op byte, v interface{}) error {
+	if d.log == nil {
+		return ErrClosed
+	}
+	data, err := d.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	body := make([]byte, 9+len(data))
+	binary.LittleEndian.PutUint64(body, d.seq+1)
+	body[8] = op
+	copy(body[9:], data)
+	err = writeRecord(d.w, body)
+	if err == nil {
+		err = d.flush(d.opts.Sync)
+	}
+	if err != nil {
+		d.discard()
+		return err
+	}
+	d.seq++
+	d.n++
+	d.off += int64(headerSize + len(body))
+	return nil
+}
+
+// discard drops whatever follows the good records, in the buffer or in the
+// log file, after a failed append. Errors of a bufio.Writer are sticky, so
+// it's reset as well.
+func (d *Tree) discard() {
+	d.w.Reset(d.log)
+	err := d.log.Truncate(d.off)
+	if err == nil {
+		_, err = d.log.Seek(d.off, io.SeekStart)
+	}
+	if err != nil {
+		d.log.Close()
+		d.log, d.w = nil, nil
+	}
+}
+
+func (d *Tree) flush(sync bool) error {
+	if err := d.w.Flush(); err != nil {
+		return err
+	}
+	if sync {
+		return d.log.Sync()
+	}
+	return nil
+}
+
+func (d *Tree) maybeSnapshot() error {
+	if d.opts.SnapshotEvery > 0 && d.n >= d.opts.SnapshotEvery {
+		return d.Snapshot()
+	}
+	return nil
+}
+
+// Snapshot writes all payloads to a new snapshot and truncates the log.
+func (d *Tree) Snapshot() error {
+	if d.log == nil {
+		return ErrClosed
+	}
+	if err := d.flush(true); err != nil {
+		return err
+	}
+	tmp := filepath.Join(d.dir, tmpName)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := d.writeSnapshot(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(d.dir, snapshotName)); err != nil {
+		return err
+	}
+	if err := syncDir(d.dir); err != nil {
+		return err
+	}
+	// Records up to d.seq are in the snapshot now, and are skipped by
+	// replay if a crash happens before the truncation.
+	if err := d.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := d.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	d.w.Reset(d.log)
+	d.n = 0
+	d.off = 0
+	return nil
+}
+
+func (d *Tree) writeSnapshot(f *os.File) error {
+	w := bufio.NewWriter(f)
+	var header [24]byte
+	copy(header[:], snapshotMagic)
+	binary.LittleEndian.PutUint64(header[8:], d.seq)
+	binary.LittleEndian.PutUint64(header[16:], uint64(d.t.Len()))
+	if err := writeRecord(w, header[:]); err != nil {
+		return err
+	}
+	var err error
+	d.t.Walk(rbtree.VisitFunc(func(x *rbtree.Node) bool {
+		var data []byte
+		if data, err = d.codec.Marshal(x.Value()); err == nil {
+			err = writeRecord(w, data)
+		}
+		return err == nil
+	}))
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (d *Tree) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(d.dir, snapshotName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header, _, err := readRecord(r)
+	if err != nil || len(header) != 24 || string(header[:8]) != snapshotMagic {
+		return ErrCorrupt
+	}
+	d.seq = binary.LittleEndian.Uint64(header[8:])
+	count := binary.LittleEndian.Uint64(header[16:])
+	var hint *rbtree.Node
+	for i := uint64(0); i < count; i++ {
+		data, _, err := readRecord(r)
+		if err != nil {
+			return ErrCorrupt
+		}
+		v, err := d.codec.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+		// Payloads are sorted, so each one follows the previous one.
+		hint, _ = d.t.InsertAfter(hint, v)
+	}
+	if d.t.Len() != int(count) {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// replay applies the log to the tree, and opens it for appending.
+func (d *Tree) replay() error {
+	f, err := os.OpenFile(filepath.Join(d.dir, logName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	good, err := d.replayLog(f)
+	if err == nil {
+		// Drop the torn tail, if any.
+		err = f.Truncate(good)
+	}
+	if err == nil {
+		_, err = f.Seek(good, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	d.log, d.w = f, bufio.NewWriter(f)
+	d.off = good
+	return nil
+}
+
+// replayLog applies the records of the log f, and returns the length of
+// the good records, which are followed by a torn tail, if any. Any other
+// damage is reported as ErrCorrupt.
+func (d *Tree) replayLog(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+	r := bufio.NewReader(f)
+	var good int64
+	for {
+		body, n, err := readRecord(r)
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			// A clean end, or a record cut short by the end of the log.
+			// The length of a record cut short in its body is valid, as
+			// readRecord checks it first.
+			return good, nil
+		case errBadRecord:
+			// The contents of the last record may have been written
+			// partially, if the file was extended first. Otherwise, good
+			// records follow.
+			if good+headerSize+int64(n) == size {
+				return good, nil
+			}
+			return 0, ErrCorrupt
+		case errBadHeader:
+			return 0, ErrCorrupt
+		default:
+			return 0, err
+		}
+		if len(body) < 9 {
+			return 0, ErrCorrupt
+		}
+		seq := binary.LittleEndian.Uint64(body)
+		if seq > d.seq {
+			if err := d.apply(body[8], body[9:]); err != nil {
+				return 0, err
+			}
+			d.seq = seq
+		}
+		good += int64(headerSize + len(body))
+		d.n++
+	}
+}
+
+func (d *Tree) apply(op byte, data []byte) error {
+	v, err := d.codec.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case opInsert:
+		d.t.Insert(v)
+	case opDelete:
+		d.t.DeleteValue(v)
+	case opReplace:
+		if x := d.t.Search(v); x != nil {
+			d.t.Replace(x, v)
+		}
+	default:
+		return fmt.Errorf("durable: unknown operation %d in log", op)
+	}
+	return nil
+}
+
+// writeRecord writes the length of body, the checksums of the length and
+// of body, followed by body.
+func writeRecord(w io.Writer, body []byte) error {
+	var header [headerSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(body)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(header[:4], crcTable))
+	binary.LittleEndian.PutUint32(header[8:], crc32.Checksum(body, crcTable))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// Errors of readRecord for damaged records
+var (
+	errBadHeader = errors.New("durable: bad record header")
+	errBadRecord = errors.New("durable: bad record")
+)
+
+// maxRecord bounds the length of records, so that a corrupted length
+// doesn't cause a huge allocation.
+const maxRecord = 1 << 30
+
+// readRecord reads a record, and returns its body and its length, which is
+// valid unless the error is errBadHeader or the header is cut short.
+func readRecord(r io.Reader) ([]byte, int, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	n := binary.LittleEndian.Uint32(header[:])
+	if crc32.Checksum(header[:4], crcTable) != binary.LittleEndian.Uint32(header[4:]) || n > maxRecord {
+		return nil, 0, errBadHeader
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, int(n), err
+	}
+	if crc32.Checksum(body, crcTable) != binary.LittleEndian.Uint32(header[8:]) {
+		return nil, int(n), errBadRecord
+	}
+	return body, int(n), nil
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,352 @@
+package durable
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fanyang01/rbtree"
+	"github.com/stretchr/testify/assert"
+)
+
+type pair struct {
+	key, value int
+}
+
+func comparePair(x, y interface{}) int {
+	return rbtree.CompareInt(x.(pair).key, y.(pair).key)
+}
+
+type pairCodec struct{}
+
+func (pairCodec) Marshal(v interface{}) ([]byte, error) {
+	p := v.(pair)
+	if p.key < 0 {
+		return nil, errors.New("negative key")
+	}
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b, uint64(p.key))
+	binary.LittleEndian.PutUint64(b[8:], uint64(p.value))
+	return b, nil
+}
+
+func (pairCodec) Unmarshal(b []byte) (interface{}, error) {
+	if len(b) != 16 {
+		return nil, errors.New("bad pair")
+	}
+	return pair{int(binary.LittleEndian.Uint64(b)), int(binary.LittleEndian.Uint64(b[8:]))}, nil
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "durable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func contents(d *Tree) []pair {
+	var ps []pair
+	d.Tree().Walk(rbtree.VisitFunc(func(x *rbtree.Node) bool {
+		ps = append(ps, x.Value().(pair))
+		return true
+	}))
+	return ps
+}
+
+// model applies random operations to d and to a map.
+func model(t *testing.T, d *Tree, m map[int]int, ops int, r *rand.Rand) {
+	for i := 0; i < ops; i++ {
+		k := r.Intn(64)
+		switch r.Intn(3) {
+		case 0:
+			_, ok, err := d.Insert(pair{k, i})
+			assert.NoError(t, err)
+			_, exists := m[k]
+			assert.Equal(t, !exists, ok)
+			if !exists {
+				m[k] = i
+			}
+		case 1:
+			_, ok, err := d.DeleteValue(pair{key: k})
+			assert.NoError(t, err)
+			_, exists := m[k]
+			assert.Equal(t, exists, ok)
+			delete(m, k)
+		case 2:
+			if x := d.Search(pair{key: k}); x != nil {
+				_, ok, err := d.Replace(x, pair{k, -i})
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				m[k] = -i
+			}
+		}
+	}
+}
+
+func mapContents(m map[int]int) []pair {
+	var ps []pair
+	for k := 0; k < 64; k++ {
+		if v, ok := m[k]; ok {
+			ps = append(ps, pair{k, v})
+		}
+	}
+	return ps
+}
+
+func TestReopen(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	r := rand.New(rand.NewSource(1))
+
+	for _, every := range []int{-1, 7, 0} {
+		os.RemoveAll(dir)
+		m := make(map[int]int)
+		for round := 0; round < 5; round++ {
+			d, err := Open(dir, comparePair, pairCodec{}, &Options{SnapshotEvery: every, Sync: round%2 == 0})
+			assert.NoError(t, err)
+			assert.Equal(t, mapContents(m), contents(d))
+			model(t, d, m, 100, r)
+			assert.Equal(t, mapContents(m), contents(d))
+			assert.NoError(t, d.Close())
+		}
+		if every > 0 {
+			// The log holds fewer records than a snapshot interval.
+			fi, err := os.Stat(filepath.Join(dir, logName))
+			assert.NoError(t, err)
+			assert.True(t, fi.Size() < int64(every*(headerSize+9+16)))
+		}
+	}
+}
+
+func TestTornLog(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, comparePair, pairCodec{}, &Options{SnapshotEvery: -1})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		d.Insert(pair{i, i})
+	}
+	assert.NoError(t, d.Snapshot())
+	var states [][]pair
+	states = append(states, contents(d))
+	for i := 10; i < 20; i++ {
+		d.Insert(pair{i, i})
+		states = append(states, contents(d))
+		d.DeleteValue(pair{key: i - 10})
+		states = append(states, contents(d))
+	}
+	// Simulate a crash: the process dies without Close.
+	assert.NoError(t, d.flush(false))
+	logPath := filepath.Join(dir, logName)
+	full, err := ioutil.ReadFile(logPath)
+	assert.NoError(t, err)
+	d.log.Close()
+
+	record := headerSize + 9 + 16
+	for cut := len(full); cut >= 0; cut-- {
+		assert.NoError(t, ioutil.WriteFile(logPath, full[:cut], 0644))
+		d, err := Open(dir, comparePair, pairCodec{}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, states[cut/record], contents(d), "cut at %d", cut)
+
+		// The torn tail is dropped, so new records follow good ones.
+		d.Insert(pair{100, 100})
+		want := contents(d)
+		assert.NoError(t, d.Close())
+		d, err = Open(dir, comparePair, pairCodec{}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, want, contents(d))
+		assert.NoError(t, d.Close())
+	}
+
+	// A corrupted last record is a torn tail, and is dropped.
+	n := len(full) / record
+	corrupt := append([]byte(nil), full...)
+	corrupt[(n-1)*record+headerSize] ^= 0xff
+	assert.NoError(t, ioutil.WriteFile(logPath, corrupt, 0644))
+	d, err = Open(dir, comparePair, pairCodec{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, states[n-1], contents(d))
+	assert.NoError(t, d.Close())
+
+	// A corrupted record followed by good ones is reported, and the log
+	// is left untouched. So is a corrupted length, even if it makes the
+	// record seem to run past the end of the log.
+	for _, i := range []int{3*record + headerSize, 3*record + 1, (n-1)*record + 1} {
+		corrupt = append([]byte(nil), full...)
+		corrupt[i] ^= 0x7f
+		assert.NoError(t, ioutil.WriteFile(logPath, corrupt, 0644))
+		_, err = Open(dir, comparePair, pairCodec{}, nil)
+		assert.Equal(t, ErrCorrupt, err, "corrupt byte %d", i)
+		data, err := ioutil.ReadFile(logPath)
+		assert.NoError(t, err)
+		assert.Equal(t, corrupt, data)
+	}
+}
+
+func TestLogFormat(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, comparePair, pairCodec{}, &Options{SnapshotEvery: -1})
+	assert.NoError(t, err)
+	d.Insert(pair{1, 2})
+	d.Replace(d.Search(pair{key: 1}), pair{1, 3})
+	d.DeleteValue(pair{key: 1})
+	assert.NoError(t, d.Close())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, logName))
+	assert.NoError(t, err)
+	// Records: length, CRC-32C of the length, CRC-32C of the body, then the
+	// body: sequence number, operation and payload.
+	var want []byte
+	for i, op := range []byte{1, 3, 2} {
+		value := []int{2, 3, 3}[i]
+		body := make([]byte, 9+16)
+		binary.LittleEndian.PutUint64(body, uint64(i+1))
+		body[8] = op
+		binary.LittleEndian.PutUint64(body[9:], 1)
+		binary.LittleEndian.PutUint64(body[17:], uint64(value))
+		table := crc32.MakeTable(crc32.Castagnoli)
+		header := make([]byte, 12)
+		binary.LittleEndian.PutUint32(header, uint32(len(body)))
+		binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(header[:4], table))
+		binary.LittleEndian.PutUint32(header[8:], crc32.Checksum(body, table))
+		want = append(append(want, header...), body...)
+	}
+	assert.Equal(t, want, data)
+}
+
+func TestSnapshotCrash(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, comparePair, pairCodec{}, &Options{SnapshotEvery: -1})
+	assert.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		d.Insert(pair{i, i})
+	}
+	d.DeleteValue(pair{key: 3})
+	d.Replace(d.Search(pair{key: 4}), pair{4, 40})
+	want := contents(d)
+	assert.NoError(t, d.flush(true))
+	logData, err := ioutil.ReadFile(filepath.Join(dir, logName))
+	assert.NoError(t, err)
+	assert.NoError(t, d.Snapshot())
+	assert.NoError(t, d.Close())
+
+	// A crash after renaming the snapshot but before truncating the log
+	// leaves records that are already in the snapshot.
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, logName), logData, 0644))
+	d, err = Open(dir, comparePair, pairCodec{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, want, contents(d))
+	assert.NoError(t, d.Close())
+
+	// A damaged snapshot is reported.
+	snap := filepath.Join(dir, snapshotName)
+	data, err := ioutil.ReadFile(snap)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(snap, data[:len(data)-1], 0644))
+	_, err = Open(dir, comparePair, pairCodec{}, nil)
+	assert.Equal(t, ErrCorrupt, err)
+}
+
+func TestErrors(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, comparePair, pairCodec{}, nil)
+	assert.NoError(t, err)
+	_, ok, err := d.Insert(pair{-1, 0})
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, d.Len())
+
+	d.Insert(pair{1, 1})
+	_, ok, err = d.Insert(pair{1, 2})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = d.Replace(d.Search(pair{key: 1}), pair{2, 2})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.True(t, d.Has(pair{key: 1}))
+
+	assert.NoError(t, d.Close())
+	assert.Equal(t, ErrClosed, d.Close())
+	_, _, err = d.Insert(pair{2, 2})
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, ErrClosed, d.Snapshot())
+}
+
+// failWriter writes up to n bytes to w, and fails after that.
+type failWriter struct {
+	w io.Writer
+	n int
+}
+
+func (f *failWriter) Write(p []byte) (int, error) {
+	if len(p) > f.n {
+		k, _ := f.w.Write(p[:f.n])
+		f.n = 0
+		return k, errors.New("write failed")
+	}
+	f.n -= len(p)
+	return f.w.Write(p)
+}
+
+func TestFailedAppend(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, comparePair, pairCodec{}, nil)
+	assert.NoError(t, err)
+	d.Insert(pair{1, 1})
+	d.Insert(pair{2, 2})
+
+	// Part of the record reaches the file before the write fails.
+	d.w = bufio.NewWriter(&failWriter{w: d.log, n: 5})
+	_, ok, err := d.Insert(pair{3, 3})
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.False(t, d.Has(pair{key: 3}))
+
+	// The failed record is gone, and later appends work.
+	_, ok, err = d.Insert(pair{4, 4})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	want := contents(d)
+	assert.NoError(t, d.Close())
+	d, err = Open(dir, comparePair, pairCodec{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, want, contents(d))
+	assert.NoError(t, d.Close())
+}
+
+func TestSnapshotError(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, comparePair, pairCodec{}, &Options{SnapshotEvery: 2})
+	assert.NoError(t, err)
+	d.Insert(pair{1, 1})
+	// Make the snapshot fail.
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, tmpName), 0755))
+
+	// The deletion triggering the snapshot is done nonetheless.
+	v, ok, err := d.DeleteValue(pair{key: 1})
+	assert.Error(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, pair{1, 1}, v)
+	assert.Equal(t, 0, d.Len())
+	assert.NoError(t, d.Close())
+}
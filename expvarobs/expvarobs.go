@@ -0,0 +1,22 @@
+// Package expvarobs exports rbtree.Counters as expvar variables. It's kept
+// out of package rbtree, since importing expvar registers an HTTP handler
+// and publishes variables as side effects.
+package expvarobs
+
+import (
+	"expvar"
+
+	"github.com/fanyang01/rbtree"
+)
+
+// Var returns an expvar.Var exporting the counters c as a JSON object
+// keyed by operation names.
+func Var(c *rbtree.Counters) expvar.Var {
+	return expvar.Func(func() interface{} { return c.Map() })
+}
+
+// Publish exports the counters c as the expvar variable name.
+// Like expvar.Publish, it panics if name is already registered.
+func Publish(name string, c *rbtree.Counters) {
+	expvar.Publish(name, Var(c))
+}
@@ -0,0 +1,24 @@
+package expvarobs
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/fanyang01/rbtree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	c := new(rbtree.Counters)
+	c.Observe(rbtree.OpCompare)
+	c.Observe(rbtree.OpCompare)
+	c.Observe(rbtree.OpRecolor)
+	Publish("rbtree_test_counters", c)
+
+	var m map[string]uint64
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get("rbtree_test_counters").String()), &m))
+	assert.Equal(t, uint64(2), m["compare"])
+	assert.Equal(t, uint64(1), m["recolor"])
+	assert.Equal(t, uint64(0), m["left_rotate"])
+}
@@ -0,0 +1,91 @@
+package rbtree
+
+// InsertAfter inserts v using hint, which should be the node immediately
+// preceding v in t. If the hint is correct, v is attached without descending
+// from the root, which costs amortized O(1) time when inserting an ascending
+// run of values. Otherwise, or if hint is nil, it falls back to Insert.
+// As with Insert, v is refused if it is already in t, and the node is returned.
+func (t *Tree) InsertAfter(hint *Node, v interface{}) (*Node, bool) {
+	if hint == nil {
+		return t.Insert(v)
+	}
+	cmp := t.compare(v, hint.v)
+	if cmp == 0 {
+		return hint, false
+	} else if cmp > 0 {
+		next := t.Next(hint)
+		if next == nil {
+			return t.insertBetween(hint, nil, v), true
+		}
+		if cmp = t.compare(v, next.v); cmp < 0 {
+			return t.insertBetween(hint, next, v), true
+		} else if cmp == 0 {
+			return next, false
+		}
+	}
+	return t.Insert(v)
+}
+
+// InsertBefore is like InsertAfter, but hint should be the node immediately
+// following v in t.
+func (t *Tree) InsertBefore(hint *Node, v interface{}) (*Node, bool) {
+	if hint == nil {
+		return t.Insert(v)
+	}
+	cmp := t.compare(v, hint.v)
+	if cmp == 0 {
+		return hint, false
+	} else if cmp < 0 {
+		prev := t.Prev(hint)
+		if prev == nil {
+			return t.insertBetween(nil, hint, v), true
+		}
+		if cmp = t.compare(v, prev.v); cmp > 0 {
+			return t.insertBetween(prev, hint, v), true
+		} else if cmp == 0 {
+			return prev, false
+		}
+	}
+	return t.Insert(v)
+}
+
+// insertBetween attaches v between the adjacent nodes prev and next,
+// either of which may be nil at the ends of t.
+func (t *Tree) insertBetween(prev, next *Node, v interface{}) *Node {
+	// If prev has a right subtree, next is its leftmost node,
+	// whose left child is empty.
+	if prev != nil && prev.right == nil {
+		return t.attach(prev, 1, v)
+	}
+	return t.attach(next, -1, v)
+}
+
+// SearchFrom is like Search, but starts from hint instead of the root.
+// It climbs from hint to the lowest ancestor whose subtree may contain v,
+// then descends from there, so the cost is logarithmic in the distance
+// between hint and v rather than in the size of t.
+// If hint is nil, it is the same as Search.
+func (t *Tree) SearchFrom(hint *Node, v interface{}) *Node {
+	if hint == nil {
+		return t.search(t.root, v)
+	}
+	cmp := t.compare(v, hint.v)
+	if cmp == 0 {
+		return hint
+	}
+	x := hint
+	for x.p != nil {
+		// Climbing from the left (right) child of p, v is bounded by p
+		// if it is less (greater) than p.
+		if cmp > 0 && x == x.p.left || cmp < 0 && x == x.p.right {
+			c := t.compare(v, x.p.v)
+			if c == 0 {
+				return x.p
+			} else if (c < 0) == (cmp > 0) {
+				break
+			}
+		}
+		x = x.p
+	}
+	return t.search(x, v)
+}
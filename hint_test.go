@@ -0,0 +1,92 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertHint(t *testing.T) {
+	n := 1 << 12
+	tr := New(CompareInt)
+
+	var hint *Node
+	for i := 0; i < n; i += 2 {
+		var ok bool
+		hint, ok = tr.InsertAfter(hint, i)
+		assert.True(t, ok)
+	}
+	for i := n - 1; i > 0; i -= 2 {
+		x, ok := tr.InsertBefore(tr.Search(i+1), i)
+		assert.True(t, ok)
+		assert.Equal(t, i, x.Value())
+	}
+	assert.Equal(t, n, tr.Len())
+	assert.True(t, checkRbTree(tr))
+	for i, x := 0, tr.First(); x != nil; i, x = i+1, tr.Next(x) {
+		assert.Equal(t, i, x.Value())
+	}
+
+	// Duplicates are refused whether or not the hint is correct.
+	for i := 0; i < 64; i++ {
+		v, h := r.Intn(n), tr.Search(r.Intn(n))
+		x, ok := tr.InsertAfter(h, v)
+		assert.False(t, ok)
+		assert.Equal(t, v, x.Value())
+		x, ok = tr.InsertBefore(h, v)
+		assert.False(t, ok)
+		assert.Equal(t, v, x.Value())
+	}
+
+	// Wrong hints fall back to a normal descent.
+	tr.Clean()
+	for i := 0; i < n; i++ {
+		v := r.Intn(n)
+		var h *Node
+		if !tr.IsEmpty() {
+			h = tr.Search(r.Intn(n))
+		}
+		if i%2 == 0 {
+			tr.InsertAfter(h, v)
+		} else {
+			tr.InsertBefore(h, v)
+		}
+		assert.True(t, tr.Has(v))
+	}
+	assert.True(t, checkRbTree(tr))
+	prev := -1
+	for x := tr.First(); x != nil; x = tr.Next(x) {
+		assert.True(t, x.Value().(int) > prev)
+		prev = x.Value().(int)
+	}
+	assert.Equal(t, prev, tr.Last().Value())
+}
+
+func TestSearchFrom(t *testing.T) {
+	n := 1 << 10
+	tr := New(CompareInt)
+	for i := 0; i < n; i += 2 {
+		tr.Insert(i)
+	}
+	for x := tr.First(); x != nil; x = tr.Next(x) {
+		for i := -1; i <= n; i++ {
+			y := tr.SearchFrom(x, i)
+			if i >= 0 && i < n && i%2 == 0 {
+				assert.NotNil(t, y)
+				assert.Equal(t, i, y.Value())
+			} else {
+				assert.Nil(t, y)
+			}
+		}
+	}
+	assert.Equal(t, tr.Search(n/2), tr.SearchFrom(nil, n/2))
+}
+
+func BenchmarkInsertAfter(b *testing.B) {
+	tr := New(CompareInt)
+	var hint *Node
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hint, _ = tr.InsertAfter(hint, i)
+	}
+}
@@ -0,0 +1,81 @@
+package rbtree
+
+// IntervalSet is a set of keys, held as disjoint half-open intervals
+// [lo, hi). Adding an interval absorbs the intervals it overlaps or
+// touches, so that intervals in the set are never adjacent.
+//
+// It is a RangeMap of which all ranges have the same value.
+type IntervalSet struct {
+	m      *RangeMap
+	length func(lo, hi interface{}) int64
+}
+
+// member is the value of all ranges of an IntervalSet.
+type member struct{}
+
+// NewIntervalSet creates an interval set of keys compared by cmp. length
+// returns the length of an interval, and is only used by TotalLength, so
+// it may be nil if TotalLength isn't called.
+func NewIntervalSet(cmp CompareFunc, length func(lo, hi interface{}) int64) *IntervalSet {
+	return &IntervalSet{m: NewRangeMap(cmp, nil), length: length}
+}
+
+// NewIntIntervalSet creates an interval set of ints.
+func NewIntIntervalSet() *IntervalSet {
+	return NewIntervalSet(CompareInt, func(lo, hi interface{}) int64 {
+		return int64(hi.(int)) - int64(lo.(int))
+	})
+}
+
+// Len returns the number of intervals in s.
+func (s *IntervalSet) Len() int { return s.m.Len() }
+
+// Add adds the keys in [lo, hi) to s.
+func (s *IntervalSet) Add(lo, hi interface{}) {
+	s.m.Set(lo, hi, member{})
+}
+
+// Remove removes the keys in [lo, hi) from s.
+func (s *IntervalSet) Remove(lo, hi interface{}) {
+	s.m.Delete(lo, hi)
+}
+
+// Contains tests if key k is in s.
+func (s *IntervalSet) Contains(k interface{}) bool {
+	_, ok := s.m.Get(k)
+	return ok
+}
+
+// Covers tests if all keys in [lo, hi) are in s, which is true for an
+// empty interval.
+func (s *IntervalSet) Covers(lo, hi interface{}) bool {
+	if s.m.cmp(lo, hi) >= 0 {
+		return true
+	}
+	r, ok := s.m.Lookup(lo)
+	return ok && s.m.cmp(hi, r.Hi) <= 0
+}
+
+// Complement returns the set of keys in [lo, hi) that are not in s.
+func (s *IntervalSet) Complement(lo, hi interface{}) *IntervalSet {
+	c := &IntervalSet{m: NewRangeMap(s.m.cmp, nil), length: s.length}
+	for _, r := range s.m.Gaps(lo, hi) {
+		c.m.t.Insert(&entry{r.Lo, r.Hi, member{}})
+	}
+	return c
+}
+
+// TotalLength returns the sum of lengths of the intervals in s.
+func (s *IntervalSet) TotalLength() int64 {
+	var n int64
+	s.m.Walk(func(r Range) bool {
+		n += s.length(r.Lo, r.Hi)
+		return true
+	})
+	return n
+}
+
+// Walk calls f with the intervals of s in order, until f returns false.
+func (s *IntervalSet) Walk(f func(lo, hi interface{}) bool) {
+	s.m.Walk(func(r Range) bool { return f(r.Lo, r.Hi) })
+}
@@ -0,0 +1,78 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntervalSet(t *testing.T) {
+	n := 64
+	s := NewIntIntervalSet()
+	ref := make([]bool, n)
+	for i := 0; i < 1000; i++ {
+		lo := r.Intn(n)
+		hi := lo + r.Intn(n-lo+1)
+		add := r.Intn(3) > 0
+		if add {
+			s.Add(lo, hi)
+		} else {
+			s.Remove(lo, hi)
+		}
+		for k := lo; k < hi; k++ {
+			ref[k] = add
+		}
+
+		var total int64
+		intervals := 0
+		for k := range ref {
+			assert.Equal(t, ref[k], s.Contains(k), "key %d", k)
+			if ref[k] {
+				total++
+				if k == 0 || !ref[k-1] {
+					intervals++
+				}
+			}
+		}
+		assert.Equal(t, total, s.TotalLength())
+		// Touching intervals are merged.
+		assert.Equal(t, intervals, s.Len())
+
+		lo = r.Intn(n)
+		hi = lo + r.Intn(n-lo+1)
+		covered := true
+		for k := lo; k < hi; k++ {
+			covered = covered && ref[k]
+		}
+		assert.Equal(t, covered, s.Covers(lo, hi), "[%d, %d)", lo, hi)
+
+		c := s.Complement(lo, hi)
+		for k := 0; k < n; k++ {
+			assert.Equal(t, k >= lo && k < hi && !ref[k], c.Contains(k))
+		}
+	}
+}
+
+func TestIntervalSetWalk(t *testing.T) {
+	s := NewIntIntervalSet()
+	s.Add(10, 20)
+	s.Add(30, 40)
+	s.Add(20, 25) // touches [10, 20)
+	s.Add(24, 31) // overlaps both
+	s.Add(50, 60)
+	s.Remove(55, 56)
+	var got [][2]interface{}
+	s.Walk(func(lo, hi interface{}) bool {
+		got = append(got, [2]interface{}{lo, hi})
+		return true
+	})
+	assert.Equal(t, [][2]interface{}{{10, 40}, {50, 55}, {56, 60}}, got)
+	assert.Equal(t, int64(39), s.TotalLength())
+	assert.True(t, s.Covers(10, 40))
+	assert.True(t, s.Covers(5, 5))
+	assert.False(t, s.Covers(50, 60))
+
+	c := s.Complement(0, 100)
+	assert.Equal(t, int64(61), c.TotalLength())
+	assert.Equal(t, 4, c.Len())
+}
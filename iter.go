@@ -1,30 +1,12 @@
 package rbtree
 
 // First returns the leftmost node in t, which is the first in-order node.
-// If t is empty, it will return nil.
-func (t *Tree) First() *Node {
-	if t.root == nil {
-		return nil
-	}
-	n := t.root
-	for n.left != nil {
-		n = n.left
-	}
-	return n
-}
+// If t is empty, it will return nil. It runs in O(1) time.
+func (t *Tree) First() *Node { return t.first }
 
 // Last returns the rightmost node in t, which is the last in-order node.
-// If t is empty, it will return nil.
-func (t *Tree) Last() *Node {
-	if t.root == nil {
-		return nil
-	}
-	n := t.root
-	for n.right != nil {
-		n = n.right
-	}
-	return n
-}
+// If t is empty, it will return nil. It runs in O(1) time.
+func (t *Tree) Last() *Node { return t.last }
 
 // Next looks up the successor of n. If n is the last node, it returns nil.
 func (t *Tree) Next(n *Node) *Node {
@@ -88,6 +70,25 @@ func (t *Tree) PostorderNext(n *Node) *Node {
 	return n.p
 }
 
+// PostorderLast returns the last post-order node of t, which obviously is the root of t.
+func (t *Tree) PostorderLast() *Node { return t.root }
+
+// PostorderPrev looks up the post-order predecessor of n.
+func (t *Tree) PostorderPrev(n *Node) *Node {
+	if n.right != nil {
+		return n.right
+	} else if n.left != nil {
+		return n.left
+	}
+	for n.p != nil {
+		if n == n.p.right && n.p.left != nil {
+			return n.p.left
+		}
+		n = n.p
+	}
+	return nil
+}
+
 // PostorderFirstNode looks up the first post-order node in subtree whose root is x. This node is the left-first deepest node.
 func (t *Tree) PostorderFirstNode(x *Node) *Node {
 	for {
@@ -120,6 +121,22 @@ func (t *Tree) PreorderNext(x *Node) *Node {
 	return nil
 }
 
+// PreorderLast looks up the last pre-order node in t.
+func (t *Tree) PreorderLast() *Node {
+	if t.root == nil {
+		return nil
+	}
+	return t.PreorderLastNode(t.root)
+}
+
+// PreorderPrev returns the pre-order predecessor of x.
+func (t *Tree) PreorderPrev(x *Node) *Node {
+	if x.p != nil && x == x.p.right && x.p.left != nil {
+		return t.PreorderLastNode(x.p.left)
+	}
+	return x.p
+}
+
 // PreorderLastNode looks up the last pre-order node in subtree whose root is x.
 func (t *Tree) PreorderLastNode(x *Node) *Node {
 	for {
@@ -132,3 +149,97 @@ func (t *Tree) PreorderLastNode(x *Node) *Node {
 		}
 	}
 }
+
+// Order is the order in which nodes are traversed.
+type Order int
+
+// Traversal orders
+const (
+	InOrder      Order = iota // ascend order of values
+	ReverseOrder              // descend order of values
+	PreOrder                  // a node before its children
+	PostOrder                 // a node after its children
+	LevelOrder                // by increasing depth, from left to right
+)
+
+// Iterator iterates over nodes of a subtree in an Order.
+// The subtree must not be modified during iteration.
+type Iterator struct {
+	t         *Tree
+	order     Order
+	next, end *Node
+	queue     []*Node // pending nodes of LevelOrder
+}
+
+// Iter returns an iterator over the subtree rooted at x, x self included, in
+// order o. If x is nil, the iterator is empty.
+func (t *Tree) Iter(x *Node, o Order) *Iterator {
+	it := &Iterator{t: t, order: o}
+	if x == nil {
+		return it
+	}
+	switch o {
+	case InOrder:
+		it.next, it.end = leftmost(x), rightmost(x)
+	case ReverseOrder:
+		it.next, it.end = rightmost(x), leftmost(x)
+	case PreOrder:
+		it.next, it.end = x, t.PreorderLastNode(x)
+	case PostOrder:
+		it.next, it.end = t.PostorderFirstNode(x), x
+	case LevelOrder:
+		it.queue = []*Node{x}
+	default:
+		panic("rbtree: unknown order")
+	}
+	return it
+}
+
+// Next returns the next node, or nil if the iteration is done.
+func (it *Iterator) Next() *Node {
+	if it.order == LevelOrder {
+		if len(it.queue) == 0 {
+			return nil
+		}
+		x := it.queue[0]
+		it.queue = it.queue[1:]
+		if x.left != nil {
+			it.queue = append(it.queue, x.left)
+		}
+		if x.right != nil {
+			it.queue = append(it.queue, x.right)
+		}
+		return x
+	}
+
+	x := it.next
+	if x == nil || x == it.end {
+		it.next = nil
+		return x
+	}
+	switch it.order {
+	case InOrder:
+		it.next = it.t.Next(x)
+	case ReverseOrder:
+		it.next = it.t.Prev(x)
+	case PreOrder:
+		it.next = it.t.PreorderNext(x)
+	case PostOrder:
+		it.next = it.t.PostorderNext(x)
+	}
+	return x
+}
+
+func leftmost(x *Node) *Node {
+	for x.left != nil {
+		x = x.left
+	}
+	return x
+}
+
+func rightmost(x *Node) *Node {
+	for x.right != nil {
+		x = x.right
+	}
+	return x
+}
@@ -44,3 +44,63 @@ func TestIter(t *testing.T) {
 		assert.NotNil(t, tr.PreorderLastNode(x))
 	}
 }
+
+func TestIterReverse(t *testing.T) {
+	n := 1 << 10
+	tr := New(CompareInt)
+
+	assert.Nil(t, tr.PostorderLast())
+	assert.Nil(t, tr.PreorderLast())
+
+	for i := 0; i < n; i++ {
+		tr.Insert(r.Intn(n))
+	}
+
+	var post, pre []*Node
+	for x := tr.PostorderFirst(); x != nil; x = tr.PostorderNext(x) {
+		post = append(post, x)
+	}
+	for x := tr.PreorderFirst(); x != nil; x = tr.PreorderNext(x) {
+		pre = append(pre, x)
+	}
+
+	assert.Equal(t, post[len(post)-1], tr.PostorderLast())
+	assert.Nil(t, tr.PostorderPrev(tr.PostorderFirst()))
+	i := len(post) - 1
+	for x := tr.PostorderLast(); x != nil; x = tr.PostorderPrev(x) {
+		assert.Equal(t, post[i], x)
+		i--
+	}
+	assert.Equal(t, -1, i)
+
+	assert.Equal(t, pre[len(pre)-1], tr.PreorderLast())
+	assert.Nil(t, tr.PreorderPrev(tr.PreorderFirst()))
+	i = len(pre) - 1
+	for x := tr.PreorderLast(); x != nil; x = tr.PreorderPrev(x) {
+		assert.Equal(t, pre[i], x)
+		i--
+	}
+	assert.Equal(t, -1, i)
+}
+
+func TestIterator(t *testing.T) {
+	orders := []Order{InOrder, ReverseOrder, PreOrder, PostOrder, LevelOrder}
+	for n := 0; n <= 64; n++ {
+		tr := New(CompareInt)
+		for i := 0; i < n; i++ {
+			tr.Insert(r.Intn(n))
+		}
+		for _, x := range append(refOrder(tr.Root(), InOrder), nil) {
+			for _, o := range orders {
+				it := tr.Iter(x, o)
+				var got []*Node
+				for y := it.Next(); y != nil; y = it.Next() {
+					got = append(got, y)
+				}
+				assert.Equal(t, refOrder(x, o), got)
+				assert.Nil(t, it.Next())
+			}
+		}
+	}
+	assert.Panics(t, func() { New(CompareInt).Iter(&Node{}, Order(-1)) })
+}
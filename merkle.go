@@ -0,0 +1,106 @@
+package rbtree
+
+import "unsafe"
+
+// HashFunc hashes a payload. Equal payloads must have equal hashes.
+type HashFunc func(v interface{}) uint64
+
+// digest is the Merkle data of a node: the digest of its own payload,
+// and the sum of digests over its subtree.
+type digest struct {
+	own, sum uint64
+}
+
+// merkleNode is a node of a Merkle tree. Only Merkle trees allocate room
+// for digests, which keeps nodes of other trees small.
+type merkleNode struct {
+	Node
+	digest digest
+}
+
+// merkle returns the digest of n, which must be a node of a Merkle tree.
+func (n *Node) merkle() *digest {
+	return &(*merkleNode)(unsafe.Pointer(n)).digest
+}
+
+// NewMerkle creates a tree in which every node keeps a hash of its payload
+// combined with the hashes of its children, so that the contents of whole
+// trees or key ranges can be compared in O(log n) time.
+//
+// Hashes are combined by addition after a bit mixing step, which makes the
+// hash of a set of payloads independent of the shape of the tree holding
+// them: two trees containing equal payloads have equal hashes, whatever the
+// order of insertions. Payloads replaced in place are rehashed.
+func NewMerkle(f CompareFunc, h HashFunc) *Tree {
+	t := New(f)
+	t.hash = h
+	t.augment = augmentDigest
+	return t
+}
+
+func augmentDigest(n *Node) {
+	d := n.merkle()
+	d.sum = d.own + digestSum(n.left) + digestSum(n.right)
+}
+
+func digestSum(n *Node) uint64 {
+	if n == nil {
+		return 0
+	}
+	return n.merkle().sum
+}
+
+// digestOf mixes the hash of v, so that sums of digests don't collide as
+// easily as sums of raw hashes (e.g. small integers).
+func (t *Tree) digestOf(v interface{}) uint64 {
+	// Finalizer of SplitMix64
+	z := t.hash(v) + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// RootHash returns the hash of all payloads in t, which is 0 if t is empty.
+// It panics if t is not created by NewMerkle.
+func (t *Tree) RootHash() uint64 {
+	t.mustMerkle()
+	return digestSum(t.root)
+}
+
+// RangeHash returns the hash of payloads in the half-open range [lo, hi)
+// of t. A nil lo or hi leaves the range unbounded on that side. Comparing
+// range hashes of two trees, and bisecting ranges that differ, finds the
+// differences between them while exchanging O(log n) hashes per difference.
+// It panics if t is not created by NewMerkle.
+func (t *Tree) RangeHash(lo, hi interface{}) uint64 {
+	t.mustMerkle()
+	h := digestSum(t.root)
+	if hi != nil {
+		h = t.hashBelow(hi)
+	}
+	if lo != nil {
+		h -= t.hashBelow(lo)
+	}
+	return h
+}
+
+// hashBelow returns the sum of digests of payloads less than v.
+func (t *Tree) hashBelow(v interface{}) uint64 {
+	var h uint64
+	x := t.root
+	for x != nil {
+		if t.compare(v, x.v) > 0 {
+			h += digestSum(x.left) + x.merkle().own
+			x = x.right
+		} else {
+			x = x.left
+		}
+	}
+	return h
+}
+
+func (t *Tree) mustMerkle() {
+	if t.hash == nil {
+		panic("rbtree: not a Merkle tree")
+	}
+}
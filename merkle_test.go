@@ -0,0 +1,128 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hashInt(v interface{}) uint64 { return uint64(v.(int)) }
+
+// checkDigest verifies the digests in the subtree rooted at x against
+// a recursive recomputation, and returns their sum.
+func checkDigest(t *testing.T, tr *Tree, x *Node) uint64 {
+	if x == nil {
+		return 0
+	}
+	assert.Equal(t, tr.digestOf(x.v), x.merkle().own)
+	sum := x.merkle().own + checkDigest(t, tr, x.left) + checkDigest(t, tr, x.right)
+	assert.Equal(t, sum, x.merkle().sum)
+	return sum
+}
+
+func TestMerkle(t *testing.T) {
+	n := 1 << 10
+	a, b := NewMerkle(CompareInt, hashInt), NewMerkle(CompareInt, hashInt)
+	assert.Equal(t, uint64(0), a.RootHash())
+
+	for i := 0; i < n; i++ {
+		a.Insert(i)
+	}
+	for _, i := range r.Perm(n) {
+		b.Insert(i)
+	}
+	checkDigest(t, a, a.root)
+	checkDigest(t, b, b.root)
+	assert.Equal(t, a.RootHash(), b.RootHash())
+
+	for i := 0; i < n/2; i++ {
+		a.DeleteValue(r.Intn(n))
+		a.InsertAfter(a.Search(r.Intn(n)), r.Intn(n))
+		a.PopFirst()
+	}
+	checkDigest(t, a, a.root)
+	assert.NotEqual(t, a.RootHash(), b.RootHash())
+
+	// Rebuild b with the same contents as a.
+	b.Clean()
+	a.WalkReverse(VisitFunc(func(x *Node) bool {
+		b.Insert(x.Value())
+		return true
+	}))
+	assert.Equal(t, a.RootHash(), b.RootHash())
+
+	// Range hashes agree with a linear scan.
+	for i := 0; i < 64; i++ {
+		lo, hi := r.Intn(n), r.Intn(n)
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		var want uint64
+		for x := a.First(); x != nil; x = a.Next(x) {
+			if v := x.Value().(int); v >= lo && v < hi {
+				want += a.digestOf(v)
+			}
+		}
+		assert.Equal(t, want, a.RangeHash(lo, hi))
+		assert.Equal(t, a.RangeHash(nil, hi)-a.RangeHash(nil, lo), a.RangeHash(lo, hi))
+	}
+	assert.Equal(t, a.RootHash(), a.RangeHash(nil, nil))
+	assert.Equal(t, a.RangeHash(nil, n/2)+a.RangeHash(n/2, nil), a.RootHash())
+
+	assert.Panics(t, func() { New(CompareInt).RootHash() })
+}
+
+func TestMerkleReplace(t *testing.T) {
+	tr := NewMerkle(comparePair, func(v interface{}) uint64 {
+		p := v.(*pair)
+		return uint64(p.key)<<32 | uint64(p.count)
+	})
+	for i := 0; i < 100; i++ {
+		tr.Insert(&pair{i, 0})
+	}
+	before := tr.RootHash()
+	tr.Replace(tr.Search(&pair{key: 50}), &pair{50, 1})
+	checkDigest(t, tr, tr.root)
+	assert.NotEqual(t, before, tr.RootHash())
+
+	tr.Update(&pair{key: 50}, func(old interface{}) interface{} { return &pair{50, 0} })
+	assert.Equal(t, before, tr.RootHash())
+	tr.Upsert(&pair{50, 2}, func(old, new interface{}) interface{} { return new })
+	checkDigest(t, tr, tr.root)
+	assert.NotEqual(t, before, tr.RootHash())
+}
+
+// diffRanges bisects [lo, hi) to find values present in only one of a and b,
+// as two replicas would do by exchanging range hashes.
+func diffRanges(a, b *Tree, lo, hi int, found *[]int) {
+	if a.RangeHash(lo, hi) == b.RangeHash(lo, hi) {
+		return
+	}
+	if hi-lo == 1 {
+		*found = append(*found, lo)
+		return
+	}
+	mid := lo + (hi-lo)/2
+	diffRanges(a, b, lo, mid, found)
+	diffRanges(a, b, mid, hi, found)
+}
+
+func TestMerkleSync(t *testing.T) {
+	n := 1 << 12
+	a, b := NewMerkle(CompareInt, hashInt), NewMerkle(CompareInt, hashInt)
+	for i := 0; i < n; i++ {
+		a.Insert(i)
+		b.Insert(i)
+	}
+	want := []int{3, 1000, 1001, 4095}
+	for _, v := range want {
+		if v%2 == 0 {
+			a.DeleteValue(v)
+		} else {
+			b.DeleteValue(v)
+		}
+	}
+	var found []int
+	diffRanges(a, b, 0, n, &found)
+	assert.Equal(t, want, found)
+}
@@ -0,0 +1,93 @@
+package rbtree
+
+import "sync/atomic"
+
+// Op is an elementary operation of a tree, which is reported to its Observer.
+type Op int
+
+// Operations reported to observers
+const (
+	OpCompare     Op = iota // a call of the CompareFunc
+	OpLeftRotate            // a left rotation
+	OpRightRotate           // a right rotation
+	OpRecolor               // a change of the color of a node by rebalancing
+	OpInsertFix             // rebalancing after an insertion
+	OpDeleteFix             // rebalancing after a deletion of a black node
+	numOps
+)
+
+var opNames = [numOps]string{
+	OpCompare:     "compare",
+	OpLeftRotate:  "left_rotate",
+	OpRightRotate: "right_rotate",
+	OpRecolor:     "recolor",
+	OpInsertFix:   "insert_fix",
+	OpDeleteFix:   "delete_fix",
+}
+
+func (op Op) String() string {
+	if op < 0 || op >= numOps {
+		return "unknown"
+	}
+	return opNames[op]
+}
+
+// Observer is notified of operations performed by a tree, synchronously
+// from the goroutine modifying or searching the tree.
+type Observer interface {
+	Observe(op Op)
+}
+
+// SetObserver makes o the observer of t. If o is nil, the observer is
+// removed, and t runs as fast as a tree that never had one.
+func (t *Tree) SetObserver(o Observer) {
+	if t.unobserved == nil {
+		t.unobserved = t.compare
+	}
+	t.observer = o
+	if o == nil {
+		t.compare = t.unobserved
+		return
+	}
+	f := t.unobserved
+	t.compare = func(x, y interface{}) int {
+		o.Observe(OpCompare)
+		return f(x, y)
+	}
+}
+
+// Observer returns the observer of t, or nil.
+func (t *Tree) Observer() Observer { return t.observer }
+
+// Counters is an Observer counting operations. It is safe for concurrent
+// use, so it can be shared by several trees.
+type Counters struct {
+	counts [numOps]uint64
+}
+
+// Observe implements the Observer interface
+func (c *Counters) Observe(op Op) {
+	atomic.AddUint64(&c.counts[op], 1)
+}
+
+// Count returns the number of operations op observed.
+func (c *Counters) Count(op Op) uint64 {
+	return atomic.LoadUint64(&c.counts[op])
+}
+
+// Reset sets all counters to zero.
+func (c *Counters) Reset() {
+	for op := range c.counts {
+		atomic.StoreUint64(&c.counts[op], 0)
+	}
+}
+
+// Map returns the counters by operation names. Package expvarobs exports
+// it as an expvar variable.
+func (c *Counters) Map() map[string]uint64 {
+	m := make(map[string]uint64, numOps)
+	for op := Op(0); op < numOps; op++ {
+		m[op.String()] = c.Count(op)
+	}
+	return m
+}
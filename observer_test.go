@@ -0,0 +1,73 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserver(t *testing.T) {
+	tr := New(CompareInt)
+	assert.Nil(t, tr.Observer())
+
+	c := new(Counters)
+	tr.SetObserver(c)
+	assert.Equal(t, c, tr.Observer())
+
+	// Ascending inserts rotate left only.
+	n := 1 << 10
+	for i := 0; i < n; i++ {
+		tr.Insert(i)
+	}
+	assert.True(t, c.Count(OpCompare) > uint64(n))
+	assert.True(t, c.Count(OpLeftRotate) > 0)
+	assert.Equal(t, uint64(0), c.Count(OpRightRotate))
+	assert.True(t, c.Count(OpRecolor) > 0)
+	assert.Equal(t, uint64(n), c.Count(OpInsertFix))
+	assert.Equal(t, uint64(0), c.Count(OpDeleteFix))
+
+	depth := tr.Search(0).Depth()
+	c.Reset()
+	tr.Search(0)
+	assert.Equal(t, uint64(depth+1), c.Count(OpCompare))
+
+	c.Reset()
+	for i := n - 1; i >= 0; i-- {
+		tr.DeleteValue(i)
+	}
+	assert.True(t, c.Count(OpDeleteFix) > 0)
+	assert.True(t, checkRbTree(tr))
+
+	// Removing the observer stops counting.
+	tr.SetObserver(nil)
+	c.Reset()
+	for i := 0; i < n; i++ {
+		tr.Insert(i)
+	}
+	assert.Equal(t, map[string]uint64{
+		"compare": 0, "left_rotate": 0, "right_rotate": 0,
+		"recolor": 0, "insert_fix": 0, "delete_fix": 0,
+	}, c.Map())
+
+	// Observers can be replaced without stacking wrappers.
+	depth = tr.Search(n / 2).Depth()
+	tr.SetObserver(c)
+	tr.SetObserver(c)
+	tr.Search(n / 2)
+	assert.Equal(t, uint64(depth+1), c.Count(OpCompare))
+
+	assert.Equal(t, "recolor", OpRecolor.String())
+	assert.Equal(t, "unknown", Op(-1).String())
+}
+
+func TestCountersMap(t *testing.T) {
+	c := new(Counters)
+	c.Observe(OpCompare)
+	c.Observe(OpCompare)
+	c.Observe(OpRecolor)
+	m := c.Map()
+	assert.Len(t, m, int(numOps))
+	assert.Equal(t, uint64(2), m["compare"])
+	assert.Equal(t, uint64(1), m["recolor"])
+	assert.Equal(t, uint64(0), m["left_rotate"])
+}
@@ -0,0 +1,175 @@
+package rbtree
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// segmentsPerWorker is the number of segments a tree is split into for
+// each worker, so that workers stay busy when segments differ in size.
+const segmentsPerWorker = 4
+
+// segment is a piece of a tree: either the whole subtree rooted at x,
+// or x alone. Segments of a partition are disjoint and ordered.
+type segment struct {
+	x      *Node
+	single bool
+}
+
+// partition splits the subtree rooted at x into segments in in-order,
+// by cutting it at nodes up to depth levels down.
+func partition(x *Node, depth int, segs []segment) []segment {
+	if x == nil {
+		return segs
+	}
+	if depth == 0 {
+		return append(segs, segment{x: x})
+	}
+	segs = partition(x.left, depth-1, segs)
+	segs = append(segs, segment{x: x, single: true})
+	return partition(x.right, depth-1, segs)
+}
+
+// walk visits nodes of s in in-order.
+func (s segment) walk(ctx context.Context, t *Tree, fn func(n *Node) error) error {
+	if s.single {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(s.x)
+	}
+	return t.WalkSubContext(ctx, ErrVisitFunc(fn), s.x, InOrder)
+}
+
+// parallel partitions t into segments for workers goroutines, and calls fn
+// for each segment on them. It returns the first error of fn, after which
+// the context passed to fn is canceled and remaining segments are skipped.
+func (t *Tree) parallel(ctx context.Context, workers int, fn func(ctx context.Context, i int, s segment) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	depth := 0
+	for 1<<uint(depth) < workers*segmentsPerWorker {
+		depth++
+	}
+	segs := partition(t.root, depth, nil)
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	work := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				if err := fn(wctx, i, segs[i]); err != nil {
+					once.Do(func() {
+						first = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+	skipped := false
+	for i := 0; i < len(segs) && !skipped; i++ {
+		select {
+		case work <- i:
+		case <-wctx.Done():
+			skipped = true
+		}
+	}
+	close(work)
+	wg.Wait()
+	if first == nil && skipped {
+		first = ctx.Err()
+	}
+	return first
+}
+
+// ParallelWalk calls fn for every node of t concurrently on up to workers
+// goroutines, in no particular order. If workers is not positive,
+// GOMAXPROCS is used. The tree is partitioned at subtree roots into disjoint
+// pieces, so t must not be modified until ParallelWalk returns.
+// If fn returns StopWalk, the other goroutines stop as soon as they notice,
+// possibly after visiting a few more nodes, and ParallelWalk returns nil.
+// If fn fails or ctx is done, remaining nodes are skipped and the first
+// error is returned.
+func (t *Tree) ParallelWalk(ctx context.Context, workers int, fn func(n *Node) error) error {
+	err := t.parallel(ctx, workers, func(ctx context.Context, _ int, s segment) error {
+		return s.walk(ctx, t, func(n *Node) error {
+			// A walk of a segment would swallow StopWalk, but all of
+			// them must stop.
+			if err := fn(n); err != StopWalk {
+				return err
+			}
+			return errStopped
+		})
+	})
+	if err == errStopped {
+		return nil
+	}
+	return err
+}
+
+// errStopped stops all walks of ParallelWalk when fn returns StopWalk.
+var errStopped = errors.New("rbtree: parallel walk stopped")
+
+// Reduce maps every node of t with mapFn and folds the results with
+// combineFn in ascend order of values, as if by
+//
+//	combineFn(...combineFn(combineFn(m1, m2), m3)..., mN)
+//
+// Disjoint subtrees are reduced concurrently on up to workers goroutines
+// and their results are combined in order, so combineFn must be associative,
+// and both functions must be safe for concurrent use. If workers is not
+// positive, GOMAXPROCS is used. t must not be modified until Reduce returns.
+// If t is empty, the result is nil. If ctx is done, ctx.Err() is returned.
+func (t *Tree) Reduce(ctx context.Context, workers int,
+	mapFn func(n *Node) interface{}, combineFn func(x, y interface{}) interface{}) (interface{}, error) {
+	type result struct {
+		v  interface{}
+		ok bool
+	}
+	var mu sync.Mutex
+	results := make(map[int]result)
+
+	err := t.parallel(ctx, workers, func(ctx context.Context, i int, s segment) error {
+		var r result
+		err := s.walk(ctx, t, func(n *Node) error {
+			if m := mapFn(n); r.ok {
+				r.v = combineFn(r.v, m)
+			} else {
+				r = result{m, true}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		results[i] = r
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var acc result
+	for i := 0; i < len(results); i++ {
+		if r := results[i]; !acc.ok {
+			acc = r
+		} else {
+			acc.v = combineFn(acc.v, r.v)
+		}
+	}
+	return acc.v, nil
+}
@@ -0,0 +1,102 @@
+package rbtree
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReduce(t *testing.T) {
+	ctx := context.Background()
+	concat := func(x, y interface{}) interface{} {
+		return append(append([]int(nil), x.([]int)...), y.([]int)...)
+	}
+	single := func(n *Node) interface{} { return []int{n.Value().(int)} }
+
+	tr := New(CompareInt)
+	v, err := tr.Reduce(ctx, 4, single, concat)
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	for _, n := range []int{1, 2, 3, 100, 1 << 12} {
+		tr.Clean()
+		want := make([]int, n)
+		for i := 0; i < n; i++ {
+			want[i] = i
+			tr.Insert(i)
+		}
+		for _, workers := range []int{0, 1, 3, 8} {
+			// Concatenation is associative but not commutative,
+			// so this checks that results are combined in order.
+			v, err := tr.Reduce(ctx, workers, single, concat)
+			assert.NoError(t, err)
+			assert.Equal(t, want, v)
+
+			sum, err := tr.Reduce(ctx, workers, func(n *Node) interface{} {
+				return n.Value()
+			}, func(x, y interface{}) interface{} {
+				return x.(int) + y.(int)
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, n*(n-1)/2, sum)
+		}
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = tr.Reduce(cctx, 4, single, concat)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestParallelWalk(t *testing.T) {
+	n := 1 << 12
+	tr := New(CompareInt)
+	for i := 0; i < n; i++ {
+		tr.Insert(i)
+	}
+	ctx := context.Background()
+
+	seen := make([]int32, n)
+	err := tr.ParallelWalk(ctx, 8, func(x *Node) error {
+		atomic.AddInt32(&seen[x.Value().(int)], 1)
+		return nil
+	})
+	assert.NoError(t, err)
+	for i := range seen {
+		assert.Equal(t, int32(1), seen[i])
+	}
+
+	fail := errors.New("fail")
+	var count int32
+	err = tr.ParallelWalk(ctx, 4, func(x *Node) error {
+		if atomic.AddInt32(&count, 1) == 10 {
+			return fail
+		}
+		return nil
+	})
+	assert.Equal(t, fail, err)
+	assert.True(t, atomic.LoadInt32(&count) < int32(n))
+
+	count = 0
+	err = tr.ParallelWalk(ctx, 4, func(x *Node) error {
+		if atomic.AddInt32(&count, 1) == 10 {
+			return StopWalk
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, atomic.LoadInt32(&count) < int32(n))
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = tr.ParallelWalk(cctx, 4, func(x *Node) error { return nil })
+	assert.Equal(t, context.Canceled, err)
+
+	assert.NoError(t, New(CompareInt).ParallelWalk(ctx, 4, func(x *Node) error {
+		t.Fatal("visited empty tree")
+		return nil
+	}))
+}
@@ -0,0 +1,199 @@
+package rbtree
+
+// RangeMap maps disjoint half-open ranges [lo, hi) of keys to values.
+// Setting a range overwrites the parts of existing ranges it overlaps,
+// splitting them if needed, and adjacent ranges with equal values are
+// coalesced into one.
+//
+// Ranges are kept in a Tree ordered by their lower bounds, so that the
+// range containing a key is found in O(log n) time.
+type RangeMap struct {
+	t     *Tree
+	cmp   CompareFunc
+	equal func(a, b interface{}) bool
+}
+
+// Range is a half-open range [Lo, Hi) of keys mapped to Value.
+type Range struct {
+	Lo, Hi, Value interface{}
+}
+
+// entry is the payload of a node of a RangeMap. Its bounds are updated in
+// place, which keeps the order of the tree since ranges are disjoint.
+type entry struct {
+	lo, hi, v interface{}
+}
+
+// NewRangeMap creates a range map of keys compared by cmp. Adjacent ranges
+// are coalesced if equal reports their values equal. If equal is nil,
+// values are compared with ==, which panics for values of uncomparable
+// types.
+func NewRangeMap(cmp CompareFunc, equal func(a, b interface{}) bool) *RangeMap {
+	if equal == nil {
+		equal = func(a, b interface{}) bool { return a == b }
+	}
+	return &RangeMap{
+		t: New(func(x, y interface{}) int {
+			return cmp(x.(*entry).lo, y.(*entry).lo)
+		}),
+		cmp:   cmp,
+		equal: equal,
+	}
+}
+
+// Len returns the number of ranges in m.
+func (m *RangeMap) Len() int { return m.t.Len() }
+
+// Get returns the value of the range containing key k.
+func (m *RangeMap) Get(k interface{}) (interface{}, bool) {
+	if r, ok := m.Lookup(k); ok {
+		return r.Value, true
+	}
+	return nil, false
+}
+
+// Lookup returns the range containing key k.
+func (m *RangeMap) Lookup(k interface{}) (Range, bool) {
+	x := m.floor(k)
+	if x == nil {
+		return Range{}, false
+	}
+	e := x.v.(*entry)
+	if m.cmp(k, e.hi) >= 0 {
+		return Range{}, false
+	}
+	return Range{e.lo, e.hi, e.v}, true
+}
+
+// Set maps the keys in [lo, hi) to v. It does nothing if the range
+// is empty.
+func (m *RangeMap) Set(lo, hi, v interface{}) {
+	if m.cmp(lo, hi) >= 0 {
+		return
+	}
+	m.Delete(lo, hi)
+
+	// No range starts in [lo, hi) now, so the range before it, if any,
+	// is the range with the greatest lower bound less than lo.
+	var prev, next *Node
+	if prev = m.floor(lo); prev != nil {
+		next = m.t.Next(prev)
+		if e := prev.v.(*entry); m.cmp(e.hi, lo) != 0 || !m.equal(e.v, v) {
+			prev = nil
+		}
+	} else {
+		next = m.t.First()
+	}
+	if next != nil {
+		if e := next.v.(*entry); m.cmp(e.lo, hi) != 0 || !m.equal(e.v, v) {
+			next = nil
+		}
+	}
+
+	switch {
+	case prev != nil && next != nil:
+		prev.v.(*entry).hi = next.v.(*entry).hi
+		m.t.Delete(next)
+	case prev != nil:
+		prev.v.(*entry).hi = hi
+	case next != nil:
+		next.v.(*entry).lo = lo
+	default:
+		m.t.Insert(&entry{lo, hi, v})
+	}
+}
+
+// Delete removes the keys in [lo, hi) from m, splitting the range
+// containing them if needed.
+func (m *RangeMap) Delete(lo, hi interface{}) {
+	if m.cmp(lo, hi) >= 0 {
+		return
+	}
+	for x := m.overlap(lo); x != nil; {
+		e := x.v.(*entry)
+		if m.cmp(e.lo, hi) >= 0 {
+			break
+		}
+		next := m.t.Next(x)
+		switch head, tail := m.cmp(e.lo, lo) < 0, m.cmp(e.hi, hi) > 0; {
+		case head && tail:
+			m.t.Insert(&entry{hi, e.hi, e.v})
+			e.hi = lo
+		case head:
+			e.hi = lo
+		case tail:
+			e.lo = hi
+		default:
+			m.t.Delete(x)
+		}
+		x = next
+	}
+}
+
+// Gaps returns the ranges of keys in [lo, hi) that are not in m, in order.
+func (m *RangeMap) Gaps(lo, hi interface{}) []Range {
+	var gaps []Range
+	if m.cmp(lo, hi) >= 0 {
+		return gaps
+	}
+	at := lo
+	for x := m.overlap(lo); x != nil; x = m.t.Next(x) {
+		e := x.v.(*entry)
+		if m.cmp(e.lo, hi) >= 0 {
+			break
+		}
+		if m.cmp(at, e.lo) < 0 {
+			gaps = append(gaps, Range{Lo: at, Hi: e.lo})
+		}
+		at = e.hi
+	}
+	if m.cmp(at, hi) < 0 {
+		gaps = append(gaps, Range{Lo: at, Hi: hi})
+	}
+	return gaps
+}
+
+// Walk calls f with the ranges of m in order, until f returns false.
+func (m *RangeMap) Walk(f func(r Range) bool) {
+	for x := m.t.First(); x != nil; x = m.t.Next(x) {
+		e := x.v.(*entry)
+		if !f(Range{e.lo, e.hi, e.v}) {
+			return
+		}
+	}
+}
+
+// WalkRange is like Walk, but only calls f with the ranges overlapping
+// [lo, hi), which are not clipped.
+func (m *RangeMap) WalkRange(lo, hi interface{}, f func(r Range) bool) {
+	if m.cmp(lo, hi) >= 0 {
+		return
+	}
+	for x := m.overlap(lo); x != nil; x = m.t.Next(x) {
+		e := x.v.(*entry)
+		if m.cmp(e.lo, hi) >= 0 || !f(Range{e.lo, e.hi, e.v}) {
+			return
+		}
+	}
+}
+
+// floor returns the node of the range with the greatest lower bound not
+// greater than k, or nil.
+func (m *RangeMap) floor(k interface{}) *Node {
+	if x := m.t.UpperBound(&entry{lo: k}); x != nil {
+		return m.t.Prev(x)
+	}
+	return m.t.Last()
+}
+
+// overlap returns the node of the first range ending after k, or nil.
+func (m *RangeMap) overlap(k interface{}) *Node {
+	x := m.floor(k)
+	if x == nil {
+		return m.t.First()
+	}
+	if m.cmp(x.v.(*entry).hi, k) <= 0 {
+		return m.t.Next(x)
+	}
+	return x
+}
@@ -0,0 +1,105 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkRangeMap checks m against ref, which maps each key to a value,
+// where -1 means no value.
+func checkRangeMap(t *testing.T, m *RangeMap, ref []int) {
+	var prev *Range
+	m.Walk(func(r Range) bool {
+		assert.True(t, r.Lo.(int) < r.Hi.(int), "empty range %v", r)
+		if prev != nil {
+			assert.True(t, prev.Hi.(int) <= r.Lo.(int), "overlap %v %v", *prev, r)
+			assert.False(t, prev.Hi == r.Lo && prev.Value == r.Value, "not coalesced %v %v", *prev, r)
+		}
+		prev = &r
+		return true
+	})
+	for k, want := range ref {
+		v, ok := m.Get(k)
+		if want < 0 {
+			assert.False(t, ok, "key %d", k)
+		} else {
+			assert.Equal(t, want, v, "key %d", k)
+		}
+	}
+}
+
+func TestRangeMap(t *testing.T) {
+	n := 64
+	m := NewRangeMap(CompareInt, nil)
+	ref := make([]int, n)
+	for i := range ref {
+		ref[i] = -1
+	}
+	for i := 0; i < 2000; i++ {
+		lo := r.Intn(n)
+		hi := lo + r.Intn(n-lo+1)
+		if r.Intn(3) == 0 {
+			m.Delete(lo, hi)
+			for k := lo; k < hi; k++ {
+				ref[k] = -1
+			}
+		} else {
+			v := r.Intn(3)
+			m.Set(lo, hi, v)
+			for k := lo; k < hi; k++ {
+				ref[k] = v
+			}
+		}
+		checkRangeMap(t, m, ref)
+
+		// Gaps by brute force
+		lo = r.Intn(n)
+		hi = lo + r.Intn(n-lo+1)
+		var gaps []Range
+		for k := lo; k < hi; k++ {
+			if ref[k] >= 0 {
+				continue
+			}
+			if l := len(gaps); l > 0 && gaps[l-1].Hi == k {
+				gaps[l-1].Hi = k + 1
+			} else {
+				gaps = append(gaps, Range{Lo: k, Hi: k + 1})
+			}
+		}
+		assert.Equal(t, gaps, m.Gaps(lo, hi))
+	}
+	assert.True(t, checkRbTree(m.t))
+}
+
+func TestRangeMapCoalesce(t *testing.T) {
+	m := NewRangeMap(CompareInt, nil)
+	m.Set(0, 10, "a")
+	m.Set(20, 30, "a")
+	m.Set(10, 20, "a")
+	assert.Equal(t, 1, m.Len())
+	r, ok := m.Lookup(15)
+	assert.True(t, ok)
+	assert.Equal(t, Range{0, 30, "a"}, r)
+
+	m.Set(5, 25, "b")
+	m.Set(30, 40, "a")
+	m.Set(40, 40, "c") // empty
+	var rs []Range
+	m.Walk(func(r Range) bool {
+		rs = append(rs, r)
+		return true
+	})
+	assert.Equal(t, []Range{{0, 5, "a"}, {5, 25, "b"}, {25, 40, "a"}}, rs)
+
+	rs = nil
+	m.WalkRange(20, 26, func(r Range) bool {
+		rs = append(rs, r)
+		return true
+	})
+	assert.Equal(t, []Range{{5, 25, "b"}, {25, 40, "a"}}, rs)
+
+	_, ok = m.Get(40)
+	assert.False(t, ok)
+	assert.Equal(t, []Range{{Lo: -5, Hi: 0}, {Lo: 40, Hi: 50}}, m.Gaps(-5, 50))
+}
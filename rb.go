@@ -4,8 +4,21 @@ package rbtree
 func isRed(n *Node) bool   { return n != nil && n.color == RED }
 func isBlack(n *Node) bool { return n == nil || n.color == BLACK }
 
-func (t *Tree) insertFix(x *Node) {
+// paint sets the color of x, and reports actual changes to the observer.
+func (t *Tree) paint(x *Node, color bool) {
+	if t.observer != nil && x.color != color {
+		t.observer.Observe(OpRecolor)
+	}
+	x.color = color
+}
+
+// insertFix restores the invariants after red x is linked. It reports
+// whether the black height of the tree grew, because the root was red.
+func (t *Tree) insertFix(x *Node) bool {
 	var y *Node
+	if t.observer != nil {
+		t.observer.Observe(OpInsertFix)
+	}
 
 	for x.p != nil && x.p.color == RED {
 		if x.p == x.p.p.left {
@@ -27,9 +40,9 @@ func (t *Tree) insertFix(x *Node) {
 				 *           /
 				 *          x
 				 */
-				x.p.color = BLACK
-				y.color = BLACK
-				x.p.p.color = RED
+				t.paint(x.p, BLACK)
+				t.paint(y, BLACK)
+				t.paint(x.p.p, RED)
 				x = x.p.p
 			} else {
 				if x == x.p.right {
@@ -64,34 +77,39 @@ func (t *Tree) insertFix(x *Node) {
 				 *                  \
 				 *                  [y]
 				 */
-				x.p.color = BLACK
-				x.p.p.color = RED
+				t.paint(x.p, BLACK)
+				t.paint(x.p.p, RED)
 				t.rightRotate(x.p.p)
 			}
 		} else {
 			y = x.p.p.left
 			if isRed(y) {
-				x.p.color = BLACK
-				y.color = BLACK
-				x.p.p.color = RED
+				t.paint(x.p, BLACK)
+				t.paint(y, BLACK)
+				t.paint(x.p.p, RED)
 				x = x.p.p
 			} else {
 				if x == x.p.left {
 					x = x.p
 					t.rightRotate(x)
 				}
-				x.p.color = BLACK
-				x.p.p.color = RED
+				t.paint(x.p, BLACK)
+				t.paint(x.p.p, RED)
 				t.leftRotate(x.p.p)
 			}
 		}
 	}
-	t.root.color = BLACK
+	grew := t.root.color == RED
+	t.paint(t.root, BLACK)
+	return grew
 }
 
 // x can be nil, but it should be treated as a leaf.
 func (t *Tree) deleteFix(p, x *Node) {
 	var y *Node
+	if t.observer != nil {
+		t.observer.Observe(OpDeleteFix)
+	}
 
 	for x != t.root && isBlack(x) {
 		if x == p.left {
@@ -111,8 +129,8 @@ func (t *Tree) deleteFix(p, x *Node) {
 				 *           / \
 				 *         [x] [a] <- y
 				 */
-				y.color = BLACK
-				p.color = RED
+				t.paint(y, BLACK)
+				t.paint(p, RED)
 				t.leftRotate(p)
 				y = p.right
 			}
@@ -131,7 +149,7 @@ func (t *Tree) deleteFix(p, x *Node) {
 				 *                   / \
 				 *                 [a] [b]
 				 */
-				y.color = RED
+				t.paint(y, RED)
 				x, p = p, p.p
 				// Don't worry :), if p is red, loop ends and it's set to black.
 			} else {
@@ -154,8 +172,8 @@ func (t *Tree) deleteFix(p, x *Node) {
 					 *                       \
 					 *                       [b]
 					 */
-					y.left.color = BLACK
-					y.color = RED
+					t.paint(y.left, BLACK)
+					t.paint(y, RED)
 					t.rightRotate(y)
 					y = p.right
 				}
@@ -173,40 +191,40 @@ func (t *Tree) deleteFix(p, x *Node) {
 				 *           / \
 				 *         [x] (a)
 				 */
-				y.color = p.color
-				p.color = BLACK
-				y.right.color = BLACK
+				t.paint(y, p.color)
+				t.paint(p, BLACK)
+				t.paint(y.right, BLACK)
 				t.leftRotate(p)
 				x, p = t.root, nil
 			}
 		} else {
 			y = p.left
 			if isRed(y) {
-				y.color = BLACK
-				p.color = RED
+				t.paint(y, BLACK)
+				t.paint(p, RED)
 				t.rightRotate(p)
 				y = p.left
 			}
 			if isBlack(y.left) && isBlack(y.right) {
-				y.color = RED
+				t.paint(y, RED)
 				x, p = p, p.p
 			} else {
 				if isBlack(y.left) {
-					y.right.color = BLACK
-					y.color = RED
+					t.paint(y.right, BLACK)
+					t.paint(y, RED)
 					t.leftRotate(y)
 					y = p.left
 				}
-				y.color = p.color
-				p.color = BLACK
-				y.left.color = BLACK
+				t.paint(y, p.color)
+				t.paint(p, BLACK)
+				t.paint(y.left, BLACK)
 				t.rightRotate(p)
 				x, p = t.root, nil
 			}
 		}
 	}
 	if x != nil {
-		x.color = BLACK
+		t.paint(x, BLACK)
 	}
 }
 
@@ -225,6 +243,10 @@ func (t *Tree) transplant(pos, n *Node) {
 }
 
 func (t *Tree) newNode(v interface{}) *Node {
+	if t.hash != nil {
+		m := &merkleNode{Node: Node{v: v, color: RED}}
+		return &m.Node
+	}
 	return &Node{
 		left:  nil,
 		right: nil,
@@ -248,6 +270,9 @@ func (t *Tree) newNode(v interface{}) *Node {
  *       a   b
  */
 func (t *Tree) leftRotate(x *Node) {
+	if t.observer != nil {
+		t.observer.Observe(OpLeftRotate)
+	}
 	y := x.right
 	x.right = y.left
 	if y.left != nil {
@@ -256,6 +281,10 @@ func (t *Tree) leftRotate(x *Node) {
 	t.transplant(x, y)
 	y.left = x
 	x.p = y
+	if t.augment != nil {
+		t.augment(x)
+		t.augment(y)
+	}
 }
 
 /*
@@ -272,6 +301,9 @@ func (t *Tree) leftRotate(x *Node) {
  *           b   c
  */
 func (t *Tree) rightRotate(x *Node) {
+	if t.observer != nil {
+		t.observer.Observe(OpRightRotate)
+	}
 	y := x.left
 	x.left = y.right
 	if y.right != nil {
@@ -280,4 +312,8 @@ func (t *Tree) rightRotate(x *Node) {
 	t.transplant(x, y)
 	y.right = x
 	x.p = y
+	if t.augment != nil {
+		t.augment(x)
+		t.augment(y)
+	}
 }
@@ -0,0 +1,402 @@
+package rbtree
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rope is a text buffer for large strings, in which inserting and deleting
+// text at any offset takes O(log n) time, where n is the number of chunks.
+//
+// Text is held in chunks of up to maxChunk bytes, which are nodes of a
+// positional tree (see Seq). Every node counts the bytes, runes and
+// newlines in its subtree, so that byte offsets, rune offsets and line
+// numbers are all located in O(log n) time.
+//
+// Offsets are byte offsets unless stated otherwise. Rune counts assume text
+// is only inserted and deleted at rune boundaries.
+type Rope struct {
+	t Tree
+}
+
+// maxChunk is the maximum length of chunks of a rope.
+const maxChunk = 4096
+
+// Metrics of chunks
+const (
+	mBytes = iota
+	mRunes
+	mLines // newlines
+	numMetrics
+)
+
+// chunk is the payload of a node of a Rope.
+type chunk struct {
+	s    string
+	size int // number of nodes in the subtree
+	own  [numMetrics]int
+	sum  [numMetrics]int // over the subtree
+}
+
+func (c *chunk) nodes() int { return c.size }
+
+func newChunk(s string) *chunk {
+	c := &chunk{size: 1}
+	c.set(s)
+	return c
+}
+
+func (c *chunk) set(s string) {
+	c.s = s
+	c.own = [numMetrics]int{len(s), utf8.RuneCountInString(s), strings.Count(s, "\n")}
+}
+
+func augmentChunk(n *Node) {
+	c := n.v.(*chunk)
+	c.size = nodeCount(n.left) + 1 + nodeCount(n.right)
+	c.sum = c.own
+	for _, x := range [2]*Node{n.left, n.right} {
+		if x != nil {
+			for m, v := range x.v.(*chunk).sum {
+				c.sum[m] += v
+			}
+		}
+	}
+}
+
+// newChunkTree returns a positional tree of the chunks of s.
+func newChunkTree(s string) *Tree {
+	t := &Tree{augment: augmentChunk}
+	for len(s) > maxChunk {
+		// Don't split runes of valid UTF-8 text.
+		i := maxChunk
+		for i > maxChunk-utf8.UTFMax && !utf8.RuneStart(s[i]) {
+			i--
+		}
+		t.insertAt(t.size, t.newNode(newChunk(s[:i])))
+		s = s[i:]
+	}
+	if s != "" {
+		t.insertAt(t.size, t.newNode(newChunk(s)))
+	}
+	return t
+}
+
+// NewRope creates a rope containing s.
+func NewRope(s string) *Rope {
+	return &Rope{t: *newChunkTree(s)}
+}
+
+func (r *Rope) total(m int) int {
+	if r.t.root == nil {
+		return 0
+	}
+	return r.t.root.v.(*chunk).sum[m]
+}
+
+// Len returns the length of r in bytes.
+func (r *Rope) Len() int { return r.total(mBytes) }
+
+// RuneLen returns the number of runes in r.
+func (r *Rope) RuneLen() int { return r.total(mRunes) }
+
+// Lines returns the number of lines in r, which is the number of newlines
+// plus one: the last line is the text after the last newline.
+func (r *Rope) Lines() int { return r.total(mLines) + 1 }
+
+// String returns the text of r.
+func (r *Rope) String() string { return r.Substring(0, r.Len()) }
+
+// Substring returns the text in the half-open range [i, j) of offsets.
+func (r *Rope) Substring(i, j int) string {
+	r.check(j, r.Len())
+	r.check(i, j)
+	if i == j {
+		return ""
+	}
+	var b strings.Builder
+	b.Grow(j - i)
+	x, k, _ := r.seek(mBytes, i)
+	for ; b.Len() < j-i; x, k = r.t.Next(x), 0 {
+		s := x.v.(*chunk).s[k:]
+		if len(s) > j-i-b.Len() {
+			s = s[:j-i-b.Len()]
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// Insert inserts s at offset off.
+func (r *Rope) Insert(off int, s string) {
+	r.check(off, r.Len())
+	if s == "" {
+		return
+	}
+	if r.t.root != nil {
+		// Insert into a chunk in place, if it's not too long.
+		var x *Node
+		var i int
+		if off == r.Len() {
+			x = r.t.last
+			i = len(x.v.(*chunk).s)
+		} else {
+			x, i, _ = r.seek(mBytes, off)
+		}
+		if c := x.v.(*chunk); len(c.s)+len(s) <= maxChunk {
+			r.setText(x, c.s[:i]+s+c.s[i:])
+			return
+		}
+	}
+	a := r.cut(off)
+	l, _, right, _ := r.t.split(r.t.root, blackHeight(r.t.root), a)
+	r.t.reset(l)
+	r.t.concat(newChunkTree(s))
+	o := &Tree{augment: augmentChunk}
+	o.reset(right)
+	r.t.concat(o)
+}
+
+// Delete deletes n bytes at offset off.
+func (r *Rope) Delete(off, n int) {
+	r.check(off, r.Len())
+	r.check(n, r.Len()-off)
+	if n == 0 {
+		return
+	}
+	// Delete from a chunk in place, if it doesn't become empty.
+	x, i, _ := r.seek(mBytes, off)
+	if c := x.v.(*chunk); i+n <= len(c.s) && n < len(c.s) {
+		r.setText(x, c.s[:i]+c.s[i+n:])
+		return
+	}
+	a := r.cut(off)
+	b := r.cut(off + n)
+	l, _, rest, h := r.t.split(r.t.root, blackHeight(r.t.root), a)
+	_, _, right, _ := r.t.split(rest, h, b-a)
+	r.t.reset(l)
+	o := &Tree{augment: augmentChunk}
+	o.reset(right)
+	r.t.concat(o)
+
+	// Merge the chunks around the deletion, if they are short.
+	if a > 0 && a < r.t.size {
+		x := r.t.at(a - 1)
+		y := r.t.Next(x)
+		if s1, s2 := x.v.(*chunk).s, y.v.(*chunk).s; len(s1)+len(s2) <= maxChunk {
+			r.setText(x, s1+s2)
+			r.t.remove(y)
+		}
+	}
+}
+
+// InsertAtRune inserts s before the rune at index i.
+func (r *Rope) InsertAtRune(i int, s string) {
+	r.Insert(r.ByteOffset(i), s)
+}
+
+// DeleteRunes deletes n runes starting at the rune at index i.
+func (r *Rope) DeleteRunes(i, n int) {
+	r.check(n, r.RuneLen()-i)
+	off := r.ByteOffset(i)
+	r.Delete(off, r.ByteOffset(i+n)-off)
+}
+
+// ByteOffset returns the offset of the rune at index i, or Len() if i is
+// RuneLen().
+func (r *Rope) ByteOffset(i int) int {
+	r.check(i, r.RuneLen())
+	if i == r.RuneLen() {
+		return r.Len()
+	}
+	x, k, start := r.seek(mRunes, i)
+	s := x.v.(*chunk).s
+	off := 0
+	for ; k > 0; k-- {
+		_, n := utf8.DecodeRuneInString(s[off:])
+		off += n
+	}
+	return start + off
+}
+
+// RuneIndex returns the number of runes before offset off.
+func (r *Rope) RuneIndex(off int) int {
+	r.check(off, r.Len())
+	return r.count(mRunes, off)
+}
+
+// LineOf returns the line containing offset off, counting from 0.
+func (r *Rope) LineOf(off int) int {
+	r.check(off, r.Len())
+	return r.count(mLines, off)
+}
+
+// LineStart returns the offset of the first byte of line k, counting
+// from 0.
+func (r *Rope) LineStart(k int) int {
+	r.check(k, r.Lines()-1)
+	if k == 0 {
+		return 0
+	}
+	// Find the newline ending line k-1.
+	x, i, start := r.seek(mLines, k-1)
+	s := x.v.(*chunk).s
+	off := 0
+	for ; i >= 0; i-- {
+		off += strings.IndexByte(s[off:], '\n') + 1
+	}
+	return start + off
+}
+
+// Line returns the text of line k, counting from 0, without the newline.
+func (r *Rope) Line(k int) string {
+	start, end := r.LineStart(k), r.Len()
+	if k+1 < r.Lines() {
+		end = r.LineStart(k+1) - 1
+	}
+	return r.Substring(start, end)
+}
+
+// Reader returns a reader of the text of r from offset off.
+func (r *Rope) Reader(off int) *RopeReader {
+	r.check(off, r.Len())
+	if off == r.Len() {
+		return &RopeReader{t: &r.t}
+	}
+	x, i, _ := r.seek(mBytes, off)
+	return &RopeReader{t: &r.t, x: x, i: i}
+}
+
+// WriteTo implements the io.WriterTo interface.
+func (r *Rope) WriteTo(w io.Writer) (int64, error) {
+	return r.Reader(0).WriteTo(w)
+}
+
+// RopeReader reads the text of a rope. It must not be used after the rope
+// is modified.
+type RopeReader struct {
+	t *Tree
+	x *Node // current chunk
+	i int   // offset in the current chunk
+}
+
+// Read implements the io.Reader interface.
+func (rd *RopeReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) && rd.x != nil {
+		k := copy(p[n:], rd.x.v.(*chunk).s[rd.i:])
+		n += k
+		rd.i += k
+		if rd.i == len(rd.x.v.(*chunk).s) {
+			rd.x, rd.i = rd.t.Next(rd.x), 0
+		}
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// WriteTo implements the io.WriterTo interface.
+func (rd *RopeReader) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for rd.x != nil {
+		k, err := io.WriteString(w, rd.x.v.(*chunk).s[rd.i:])
+		n += int64(k)
+		rd.i += k
+		if err != nil {
+			return n, err
+		}
+		rd.x, rd.i = rd.t.Next(rd.x), 0
+	}
+	return n, nil
+}
+
+// seek returns the chunk containing the unit at index i of metric m, the
+// index of the unit within the chunk, and the offset of the chunk.
+// i must be less than the total of m.
+func (r *Rope) seek(m, i int) (x *Node, k, start int) {
+	x = r.t.root
+	for {
+		c := x.v.(*chunk)
+		var left [numMetrics]int
+		if x.left != nil {
+			left = x.left.v.(*chunk).sum
+		}
+		if i < left[m] {
+			x = x.left
+			continue
+		}
+		i -= left[m]
+		start += left[mBytes]
+		if i < c.own[m] {
+			return x, i, start
+		}
+		i -= c.own[m]
+		start += c.own[mBytes]
+		x = x.right
+	}
+}
+
+// count returns the total of metric m over the text before offset off.
+func (r *Rope) count(m, off int) int {
+	n := 0
+	x := r.t.root
+	for x != nil {
+		c := x.v.(*chunk)
+		var left [numMetrics]int
+		if x.left != nil {
+			left = x.left.v.(*chunk).sum
+		}
+		if off < left[mBytes] {
+			x = x.left
+			continue
+		}
+		off -= left[mBytes]
+		n += left[m]
+		if off < c.own[mBytes] {
+			switch m {
+			case mRunes:
+				return n + utf8.RuneCountInString(c.s[:off])
+			case mLines:
+				return n + strings.Count(c.s[:off], "\n")
+			}
+			return n + off
+		}
+		off -= c.own[mBytes]
+		n += c.own[m]
+		x = x.right
+	}
+	return n
+}
+
+// cut splits the chunk containing offset off, if off is inside it, and
+// returns the index of the chunk starting at off, which is the number of
+// chunks if off is the end.
+func (r *Rope) cut(off int) int {
+	if off == r.Len() {
+		return r.t.size
+	}
+	x, i, _ := r.seek(mBytes, off)
+	if i > 0 {
+		s := x.v.(*chunk).s
+		r.setText(x, s[:i])
+		y := r.t.newNode(newChunk(s[i:]))
+		r.t.insertAt(r.t.index(x)+1, y)
+		x = y
+	}
+	return r.t.index(x)
+}
+
+// setText replaces the text of chunk x with s.
+func (r *Rope) setText(x *Node, s string) {
+	x.v.(*chunk).set(s)
+	r.t.augmentPath(x)
+}
+
+func (r *Rope) check(i, max int) {
+	if i < 0 || i > max {
+		panic("rbtree: offset out of range")
+	}
+}
@@ -0,0 +1,128 @@
+package rbtree
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomText returns n bytes of text with newlines and multi-byte runes.
+func randomText(n int) string {
+	const alphabet = "abcdefgh\né世\U0001f600"
+	runes := []rune(alphabet)
+	var b strings.Builder
+	for b.Len() < n {
+		b.WriteRune(runes[r.Intn(len(runes))])
+	}
+	return b.String()
+}
+
+func checkRope(t *testing.T, rp *Rope, s string) {
+	assert.Equal(t, len(s), rp.Len())
+	assert.Equal(t, utf8.RuneCountInString(s), rp.RuneLen())
+	assert.Equal(t, strings.Count(s, "\n")+1, rp.Lines())
+	assert.True(t, s == rp.String(), "text differs")
+	assert.True(t, checkRbTree(&rp.t))
+	checkCount(t, rp.t.root)
+	rp.t.Walk(VisitFunc(func(x *Node) bool {
+		c := x.v.(*chunk)
+		assert.NotEmpty(t, c.s)
+		assert.True(t, len(c.s) <= maxChunk)
+		return true
+	}))
+}
+
+func TestRopeEdit(t *testing.T) {
+	s := randomText(3 * maxChunk)
+	rp := NewRope(s)
+	checkRope(t, rp, s)
+
+	for i := 0; i < 300; i++ {
+		runes := []rune(s)
+		ri := r.Intn(len(runes) + 1)
+		off := len(string(runes[:ri]))
+		assert.Equal(t, off, rp.ByteOffset(ri))
+		assert.Equal(t, ri, rp.RuneIndex(off))
+
+		switch r.Intn(4) {
+		case 0:
+			u := randomText(r.Intn(2 * maxChunk))
+			rp.Insert(off, u)
+			s = s[:off] + u + s[off:]
+		case 1:
+			u := randomText(r.Intn(16))
+			rp.InsertAtRune(ri, u)
+			s = s[:off] + u + s[off:]
+		case 2:
+			n := r.Intn(len(runes) - ri + 1)
+			rp.DeleteRunes(ri, n)
+			s = string(runes[:ri]) + string(runes[ri+n:])
+		case 3:
+			end := len(string(runes[:ri+r.Intn(len(runes)-ri+1)]))
+			rp.Delete(off, end-off)
+			s = s[:off] + s[end:]
+		}
+		if i%30 == 0 {
+			checkRope(t, rp, s)
+		}
+	}
+	checkRope(t, rp, s)
+
+	for i := 0; i < 50; i++ {
+		a := r.Intn(len(s) + 1)
+		b := a + r.Intn(len(s)-a+1)
+		assert.True(t, s[a:b] == rp.Substring(a, b))
+	}
+	rp.Delete(0, rp.Len())
+	checkRope(t, rp, "")
+	assert.Panics(t, func() { rp.Insert(1, "x") })
+}
+
+func TestRopeLines(t *testing.T) {
+	s := randomText(5 * maxChunk)
+	rp := NewRope(s)
+	lines := strings.Split(s, "\n")
+	assert.Equal(t, len(lines), rp.Lines())
+	off := 0
+	for k, line := range lines {
+		assert.Equal(t, off, rp.LineStart(k))
+		assert.Equal(t, line, rp.Line(k))
+		assert.Equal(t, k, rp.LineOf(off))
+		assert.Equal(t, k, rp.LineOf(off+len(line)))
+		off += len(line) + 1
+	}
+	assert.Panics(t, func() { rp.LineStart(len(lines)) })
+
+	rp = NewRope("")
+	assert.Equal(t, 1, rp.Lines())
+	assert.Equal(t, "", rp.Line(0))
+	rp.Insert(0, "a\n\nb")
+	assert.Equal(t, []string{"a", "", "b"}, []string{rp.Line(0), rp.Line(1), rp.Line(2)})
+}
+
+func TestRopeReader(t *testing.T) {
+	s := randomText(3*maxChunk + 17)
+	rp := NewRope(s)
+
+	var buf bytes.Buffer
+	n, err := rp.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(s)), n)
+	assert.True(t, s == buf.String())
+
+	for _, off := range []int{0, 1, maxChunk, len(s) - 1, len(s)} {
+		// Hide WriteTo, so that ReadAll calls Read, across chunk
+		// boundaries.
+		b, err := ioutil.ReadAll(io.LimitReader(struct{ io.Reader }{rp.Reader(off)}, int64(len(s))))
+		assert.NoError(t, err)
+		assert.True(t, s[off:] == string(b))
+	}
+	p := make([]byte, 10)
+	_, err = rp.Reader(len(s)).Read(p)
+	assert.Equal(t, io.EOF, err)
+}
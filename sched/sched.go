@@ -0,0 +1,211 @@
+// Package sched runs callbacks at given times. Pending timers are kept in
+// an rbtree.Tree ordered by fire time, with ties broken by the order of
+// scheduling, and are fired one by one from a single goroutine.
+package sched
+
+import (
+	"sync"
+	"time"
+
+	"github.com/fanyang01/rbtree"
+)
+
+// Scheduler fires timers. It is safe for concurrent use, and callbacks may
+// schedule, reset and cancel timers.
+type Scheduler struct {
+	clock Clock
+	wake  chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	mu  sync.Mutex
+	t   *rbtree.Tree // of items
+	seq uint64
+}
+
+// Timer is a handle of a callback scheduled by a Scheduler.
+type Timer struct {
+	s *Scheduler
+	f func()
+	n *rbtree.Node // nil unless pending
+}
+
+// item is the payload of a node of the tree of a Scheduler. A pending
+// timer is moved by rekeying its node with a new item, so that the node
+// stays its handle.
+type item struct {
+	when time.Time
+	seq  uint64
+	tm   *Timer
+}
+
+func compareItem(x, y interface{}) int {
+	a, b := x.(item), y.(item)
+	switch {
+	case a.when.Before(b.when):
+		return -1
+	case a.when.After(b.when):
+		return 1
+	case a.seq < b.seq:
+		return -1
+	case a.seq > b.seq:
+		return 1
+	}
+	return 0
+}
+
+// New creates a scheduler running on clock, or on RealClock if clock is nil,
+// and starts its goroutine.
+func New(clock Clock) *Scheduler {
+	if clock == nil {
+		clock = RealClock
+	}
+	s := &Scheduler{
+		clock: clock,
+		wake:  make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+		t:     rbtree.New(compareItem),
+	}
+	go s.run()
+	return s
+}
+
+// Schedule makes f run at when, or as soon as possible if when has passed.
+// Timers due at the same time run in the order they were scheduled.
+func (s *Scheduler) Schedule(when time.Time, f func()) *Timer {
+	tm := &Timer{s: s, f: f}
+	s.mu.Lock()
+	tm.n, _ = s.t.Insert(s.item(when, tm))
+	s.mu.Unlock()
+	s.notify()
+	return tm
+}
+
+// After makes f run after duration d.
+func (s *Scheduler) After(d time.Duration, f func()) *Timer {
+	return s.Schedule(s.clock.Now().Add(d), f)
+}
+
+// Len returns the number of pending timers.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.t.Len()
+}
+
+// Stop stops the goroutine of s, after the running callback returns, if
+// any. Pending timers never fire. It must not be called from a callback.
+func (s *Scheduler) Stop() {
+	s.once.Do(func() { close(s.quit) })
+	<-s.done
+}
+
+// Reset makes tm fire at when, whether it is pending, has fired or has been
+// cancelled. It reports whether tm was pending.
+func (tm *Timer) Reset(when time.Time) bool {
+	s := tm.s
+	s.mu.Lock()
+	pending := tm.n != nil
+	if pending {
+		// Sequence numbers make items unique, so Rekey can't fail.
+		s.t.Rekey(tm.n, s.item(when, tm))
+	} else {
+		tm.n, _ = s.t.Insert(s.item(when, tm))
+	}
+	s.mu.Unlock()
+	s.notify()
+	return pending
+}
+
+// Cancel prevents tm from firing. It reports whether tm was pending.
+func (tm *Timer) Cancel() bool {
+	s := tm.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tm.n == nil {
+		return false
+	}
+	s.t.Delete(tm.n)
+	tm.n = nil
+	return true
+}
+
+// When returns the time at which tm fires, or the zero time if tm is
+// not pending.
+func (tm *Timer) When() time.Time {
+	tm.s.mu.Lock()
+	defer tm.s.mu.Unlock()
+	if tm.n == nil {
+		return time.Time{}
+	}
+	return tm.n.Value().(item).when
+}
+
+func (s *Scheduler) item(when time.Time, tm *Timer) item {
+	s.seq++
+	return item{when: when, seq: s.seq, tm: tm}
+}
+
+// notify wakes the goroutine of s up to look at the first timer again.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run() {
+	defer close(s.done)
+	for {
+		// Fire due timers one at a time, so that a callback can cancel
+		// timers due at the same time.
+		var next time.Time
+		var pending bool
+		for {
+			// Stop may have been called by now, during a callback. The
+			// timers still pending must stay so.
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			s.mu.Lock()
+			v, ok := s.t.PeekFirst()
+			if !ok {
+				s.mu.Unlock()
+				break
+			}
+			it := v.(item)
+			if it.when.After(s.clock.Now()) {
+				s.mu.Unlock()
+				next, pending = it.when, true
+				break
+			}
+			s.t.PopFirst()
+			it.tm.n = nil
+			s.mu.Unlock()
+			it.tm.f()
+		}
+
+		var c <-chan time.Time
+		var timer ClockTimer
+		if pending {
+			timer = s.clock.NewTimer(next.Sub(s.clock.Now()))
+			c = timer.C()
+		}
+		select {
+		case <-c:
+		case <-s.wake:
+		case <-s.quit:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
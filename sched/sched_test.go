@@ -0,0 +1,151 @@
+package sched
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var epoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// barrier waits until the timers of s due by now have fired, by scheduling
+// a timer at now, which fires after them.
+func barrier(s *Scheduler, now time.Time) {
+	done := make(chan struct{})
+	s.Schedule(now, func() { close(done) })
+	<-done
+}
+
+func TestOrder(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	defer s.Stop()
+
+	fired := make(chan string, 8)
+	at := func(d time.Duration, name string) *Timer {
+		return s.Schedule(epoch.Add(d), func() { fired <- name })
+	}
+	at(3*time.Second, "a")
+	at(time.Second, "b")
+	at(2*time.Second, "c")
+	at(time.Second, "d") // ties fire in order of scheduling
+	at(10*time.Second, "e")
+	assert.Equal(t, 5, s.Len())
+
+	clock.Advance(5 * time.Second)
+	barrier(s, clock.Now())
+	close(fired)
+	var got []string
+	for name := range fired {
+		got = append(got, name)
+	}
+	assert.Equal(t, []string{"b", "d", "c", "a"}, got)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestResetCancel(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	defer s.Stop()
+
+	fired := make(chan time.Time, 8)
+	tm := s.After(10*time.Second, func() { fired <- clock.Now() })
+	assert.Equal(t, epoch.Add(10*time.Second), tm.When())
+
+	assert.True(t, tm.Reset(epoch.Add(2*time.Second)))
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, epoch.Add(2*time.Second), <-fired)
+	assert.True(t, tm.When().IsZero())
+
+	// Reset after firing schedules the timer again.
+	assert.False(t, tm.Reset(epoch.Add(4*time.Second)))
+	assert.True(t, tm.Cancel())
+	assert.False(t, tm.Cancel())
+	clock.Advance(10 * time.Second)
+	barrier(s, clock.Now())
+	assert.Len(t, fired, 0)
+	assert.Equal(t, 0, s.Len())
+
+	assert.False(t, tm.Reset(clock.Now().Add(time.Second)))
+	clock.Advance(time.Second)
+	assert.Equal(t, epoch.Add(13*time.Second), <-fired)
+}
+
+func TestCancelFromCallback(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	defer s.Stop()
+
+	fired := make(chan string, 8)
+	var b *Timer
+	s.Schedule(epoch.Add(time.Second), func() {
+		fired <- "a"
+		b.Cancel()
+		s.Schedule(epoch, func() { fired <- "c" }) // already due
+	})
+	b = s.Schedule(epoch.Add(time.Second), func() { fired <- "b" })
+
+	clock.Advance(time.Second)
+	barrier(s, clock.Now())
+	close(fired)
+	var got []string
+	for name := range fired {
+		got = append(got, name)
+	}
+	assert.Equal(t, []string{"a", "c"}, got)
+}
+
+func TestStop(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	fired := false
+	s.After(time.Second, func() { fired = true })
+	s.Stop()
+	s.Stop()
+	clock.Advance(time.Second)
+	assert.False(t, fired)
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStopDuringCallback(t *testing.T) {
+	clock := NewFakeClock(epoch)
+	s := New(clock)
+	started, release := make(chan struct{}), make(chan struct{})
+	s.Schedule(epoch, func() {
+		close(started)
+		<-release
+	})
+	fired := false
+	tm := s.Schedule(epoch, func() { fired = true })
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	<-s.quit
+	close(release)
+	<-stopped
+
+	// The second timer was due, but stays pending.
+	assert.False(t, fired)
+	assert.Equal(t, 1, s.Len())
+	assert.Equal(t, epoch, tm.When())
+	assert.True(t, tm.Cancel())
+}
+
+func TestRealClock(t *testing.T) {
+	s := New(nil)
+	defer s.Stop()
+	fired := make(chan struct{})
+	start := time.Now()
+	s.After(10*time.Millisecond, func() { close(fired) })
+	select {
+	case <-fired:
+		assert.True(t, time.Since(start) >= 10*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer didn't fire")
+	}
+}
@@ -0,0 +1,320 @@
+package rbtree
+
+// Seq is a sequence of values indexed by position, like a slice, in which
+// inserting and removing values at any index takes O(log n) time.
+//
+// It is a red-black tree ordered by position instead of a CompareFunc: each
+// node counts the nodes in its subtree, which locates an index in O(log n)
+// time. Sequences are concatenated and split in O(log n) time as well, by
+// joining red-black trees of different black heights.
+type Seq struct {
+	t Tree
+}
+
+// elem is the payload of a node of a Seq.
+type elem struct {
+	v    interface{}
+	size int // number of nodes in the subtree
+}
+
+// counted is implemented by payloads of positional trees, which know the
+// number of nodes in the subtrees of their nodes.
+type counted interface {
+	nodes() int
+}
+
+func (e *elem) nodes() int { return e.size }
+
+func nodeCount(x *Node) int {
+	if x == nil {
+		return 0
+	}
+	return x.v.(counted).nodes()
+}
+
+func augmentElem(n *Node) {
+	n.v.(*elem).size = nodeCount(n.left) + 1 + nodeCount(n.right)
+}
+
+// NewSeq creates a sequence containing vs.
+func NewSeq(vs ...interface{}) *Seq {
+	s := &Seq{t: Tree{augment: augmentElem}}
+	for _, v := range vs {
+		s.Append(v)
+	}
+	return s
+}
+
+// Len returns the number of values in s.
+func (s *Seq) Len() int { return s.t.size }
+
+// At returns the value at index i.
+func (s *Seq) At(i int) interface{} {
+	s.check(i, s.t.size-1)
+	return s.t.at(i).v.(*elem).v
+}
+
+// Set replaces the value at index i with v.
+func (s *Seq) Set(i int, v interface{}) {
+	s.check(i, s.t.size-1)
+	s.t.at(i).v.(*elem).v = v
+}
+
+// Append adds v at the end of s.
+func (s *Seq) Append(v interface{}) {
+	s.InsertAt(s.t.size, v)
+}
+
+// InsertAt inserts v at index i, shifting the values at and after i.
+// i may be Len(), which appends v.
+func (s *Seq) InsertAt(i int, v interface{}) {
+	s.check(i, s.t.size)
+	s.t.insertAt(i, s.t.newNode(&elem{v: v, size: 1}))
+}
+
+// RemoveAt removes the value at index i and returns it.
+func (s *Seq) RemoveAt(i int) interface{} {
+	s.check(i, s.t.size-1)
+	x := s.t.at(i)
+	s.t.remove(x)
+	return x.v.(*elem).v
+}
+
+// Slice returns the values in the half-open range [i, j) of indexes.
+func (s *Seq) Slice(i, j int) []interface{} {
+	s.check(j, s.t.size)
+	s.check(i, j)
+	vs := make([]interface{}, 0, j-i)
+	if i == j {
+		return vs
+	}
+	for x := s.t.at(i); len(vs) < j-i; x = s.t.Next(x) {
+		vs = append(vs, x.v.(*elem).v)
+	}
+	return vs
+}
+
+// Concat moves the values of o to the end of s, leaving o empty.
+func (s *Seq) Concat(o *Seq) {
+	if s == o {
+		panic("rbtree: concatenating a sequence with itself")
+	}
+	s.t.concat(&o.t)
+}
+
+// SplitAt truncates s to its first i values, and returns a new sequence of
+// the values that followed.
+func (s *Seq) SplitAt(i int) *Seq {
+	s.check(i, s.t.size)
+	o := &Seq{t: Tree{augment: augmentElem}}
+	l, _, r, _ := s.t.split(s.t.root, blackHeight(s.t.root), i)
+	s.t.reset(l)
+	o.t.reset(r)
+	return o
+}
+
+func (s *Seq) check(i, max int) {
+	if i < 0 || i > max {
+		panic("rbtree: index out of range")
+	}
+}
+
+// Positional trees. The functions below only rely on nodeCount, and keep
+// data of augmented trees up to date.
+
+// at returns the node at index i of a positional tree.
+func (t *Tree) at(i int) *Node {
+	x := t.root
+	for {
+		l := nodeCount(x.left)
+		if i < l {
+			x = x.left
+		} else if i > l {
+			i -= l + 1
+			x = x.right
+		} else {
+			return x
+		}
+	}
+}
+
+// index returns the index of x in a positional tree.
+func (t *Tree) index(x *Node) int {
+	i := nodeCount(x.left)
+	for ; x.p != nil; x = x.p {
+		if x == x.p.right {
+			i += nodeCount(x.p.left) + 1
+		}
+	}
+	return i
+}
+
+// insertAt attaches the new node n at index i of a positional tree.
+func (t *Tree) insertAt(i int, n *Node) {
+	switch {
+	case t.root == nil:
+		t.attachNode(nil, 0, n)
+	case i == t.size:
+		t.attachNode(t.last, 1, n)
+	default:
+		x := t.at(i)
+		if x.left == nil {
+			t.attachNode(x, -1, n)
+		} else {
+			t.attachNode(rightmost(x.left), 1, n)
+		}
+	}
+}
+
+// concat moves the nodes of o to the end of a positional tree.
+func (t *Tree) concat(o *Tree) {
+	if o.root == nil {
+		return
+	}
+	if t.root == nil {
+		t.reset(o.root)
+		o.reset(nil)
+		return
+	}
+	k := o.first
+	o.remove(k)
+	root, _ := t.join(t.root, blackHeight(t.root), k, o.root, blackHeight(o.root))
+	t.reset(root)
+	o.reset(nil)
+}
+
+// reset makes root, which may be nil, the root of t.
+func (t *Tree) reset(root *Node) {
+	t.root = root
+	t.size = nodeCount(root)
+	t.first, t.last = nil, nil
+	if root != nil {
+		root.p = nil
+		t.first, t.last = leftmost(root), rightmost(root)
+	}
+}
+
+// split splits the red-black tree x of black height h into trees of its
+// first i nodes and the rest, and returns them with their black heights.
+// The roots of the results are black.
+//
+// Black heights are passed down and up instead of being recomputed, so
+// that joins on the way up take O(log n) time in total, not per join.
+func (t *Tree) split(x *Node, h, i int) (l *Node, hl int, r *Node, hr int) {
+	if x == nil {
+		return nil, 0, nil, 0
+	}
+	left, hleft := detach(x.left, h)
+	right, hright := detach(x.right, h)
+	n := nodeCount(left)
+	if i <= n {
+		l, hl, r, hr = t.split(left, hleft, i)
+		r, hr = t.join(r, hr, x, right, hright)
+		return l, hl, r, hr
+	}
+	l, hl, r, hr = t.split(right, hright, i-n-1)
+	l, hl = t.join(left, hleft, x, l, hl)
+	return l, hl, r, hr
+}
+
+// detach unlinks the subtree x from its black parent of black height h,
+// making it a red-black tree on its own, and returns it with its black
+// height.
+func detach(x *Node, h int) (*Node, int) {
+	h--
+	if x != nil {
+		x.p = nil
+		if x.color == RED {
+			x.color = BLACK
+			h++
+		}
+	}
+	return x, h
+}
+
+// join links the node k between trees l and r of black heights hl and hr,
+// whose roots are black, and returns the root of the resulting red-black
+// tree, which is black, and its black height. It takes O(|hl - hr| + 1)
+// time.
+func (t *Tree) join(l *Node, hl int, k, r *Node, hr int) (*Node, int) {
+	k.p, k.left, k.right = nil, nil, nil
+	if hl == hr {
+		k.color = BLACK
+		k.left, k.right = l, r
+		if l != nil {
+			l.p = k
+		}
+		if r != nil {
+			r.p = k
+		}
+		if t.augment != nil {
+			t.augment(k)
+		}
+		return k, hl + 1
+	}
+
+	// Descend the spine of the higher tree facing the other one, to a black
+	// node y as high as the other tree, and replace y by red k with children
+	// y and the other tree. Only a red-red violation above k may remain.
+	// Rotations of the fixup need a tree to update its root.
+	s := Tree{augment: t.augment, observer: t.observer}
+	k.color = RED
+	h := hl
+	if hl > hr {
+		s.root = l
+		y, d := l, hl
+		for !(isBlack(y) && d == hr) {
+			if isBlack(y) {
+				d--
+			}
+			y = y.right
+		}
+		if y != nil {
+			k.p = y.p
+		} else {
+			k.p = rightmost(l)
+		}
+		k.p.right = k
+		k.left, k.right = y, r
+	} else {
+		h = hr
+		s.root = r
+		y, d := r, hr
+		for !(isBlack(y) && d == hl) {
+			if isBlack(y) {
+				d--
+			}
+			y = y.left
+		}
+		if y != nil {
+			k.p = y.p
+		} else {
+			k.p = leftmost(r)
+		}
+		k.p.left = k
+		k.left, k.right = l, y
+	}
+	if k.left != nil {
+		k.left.p = k
+	}
+	if k.right != nil {
+		k.right.p = k
+	}
+	s.augmentPath(k)
+	if s.insertFix(k) {
+		h++
+	}
+	return s.root, h
+}
+
+// blackHeight returns the number of black nodes on a path from x to a leaf.
+// It takes O(log n) time.
+func blackHeight(x *Node) int {
+	h := 0
+	for ; x != nil; x = x.left {
+		if x.color == BLACK {
+			h++
+		}
+	}
+	return h
+}
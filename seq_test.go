@@ -0,0 +1,121 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkSeq checks s against the reference slice vs.
+func checkSeq(t *testing.T, s *Seq, vs []interface{}) {
+	assert.Equal(t, len(vs), s.Len())
+	assert.Equal(t, vs, s.Slice(0, s.Len()))
+	assert.True(t, checkRbTree(&s.t))
+	checkCount(t, s.t.root)
+	if s.Len() > 0 {
+		assert.Equal(t, s.t.first, leftmost(s.t.root))
+		assert.Equal(t, s.t.last, rightmost(s.t.root))
+		assert.Nil(t, s.t.root.p)
+	}
+}
+
+func checkCount(t *testing.T, x *Node) int {
+	if x == nil {
+		return 0
+	}
+	if x.left != nil {
+		assert.Equal(t, x, x.left.p)
+	}
+	if x.right != nil {
+		assert.Equal(t, x, x.right.p)
+	}
+	n := checkCount(t, x.left) + 1 + checkCount(t, x.right)
+	assert.Equal(t, n, nodeCount(x))
+	return n
+}
+
+func TestSeq(t *testing.T) {
+	s := NewSeq()
+	var ref []interface{}
+	for i := 0; i < 1000; i++ {
+		switch {
+		case len(ref) == 0 || r.Intn(3) > 0:
+			j := r.Intn(len(ref) + 1)
+			s.InsertAt(j, i)
+			ref = append(ref[:j], append([]interface{}{i}, ref[j:]...)...)
+		default:
+			j := r.Intn(len(ref))
+			assert.Equal(t, ref[j], s.RemoveAt(j))
+			ref = append(ref[:j], ref[j+1:]...)
+		}
+	}
+	checkSeq(t, s, ref)
+	for j := range ref {
+		assert.Equal(t, ref[j], s.At(j))
+	}
+	s.Set(3, "x")
+	ref[3] = "x"
+	assert.Equal(t, ref[2:5], s.Slice(2, 5))
+	assert.Empty(t, s.Slice(4, 4))
+
+	assert.Panics(t, func() { s.At(s.Len()) })
+	assert.Panics(t, func() { s.InsertAt(-1, 0) })
+	assert.Panics(t, func() { s.Slice(3, 2) })
+	assert.Panics(t, func() { s.Concat(s) })
+}
+
+func TestSeqSplitConcat(t *testing.T) {
+	var ref []interface{}
+	for i := 0; i < 500; i++ {
+		ref = append(ref, i)
+	}
+	for _, i := range []int{0, 1, 250, 499, 500} {
+		s := NewSeq(ref...)
+		o := s.SplitAt(i)
+		checkSeq(t, s, ref[:i])
+		checkSeq(t, o, ref[i:])
+
+		s.Concat(o)
+		checkSeq(t, s, ref)
+		checkSeq(t, o, []interface{}{})
+	}
+
+	// Concatenate sequences of very different sizes, and split them
+	// at random indexes.
+	for round := 0; round < 50; round++ {
+		a, b := r.Intn(300), r.Intn(30)
+		if r.Intn(2) == 0 {
+			a, b = b, a
+		}
+		s, o := NewSeq(ref[:a]...), NewSeq(ref[a:a+b]...)
+		s.Concat(o)
+		checkSeq(t, s, ref[:a+b])
+
+		i := r.Intn(a + b + 1)
+		o = s.SplitAt(i)
+		checkSeq(t, s, ref[:i])
+		checkSeq(t, o, ref[i:a+b])
+		o.InsertAt(0, -1)
+		s.Append(-2)
+		assert.Equal(t, -2, s.At(i))
+		assert.Equal(t, -1, o.At(0))
+	}
+}
+
+func TestSplitBlackHeights(t *testing.T) {
+	var ref []interface{}
+	for i := 0; i < 300; i++ {
+		ref = append(ref, i)
+	}
+	for i := 0; i <= len(ref); i += 7 {
+		s := NewSeq(ref...)
+		l, hl, r, hr := s.t.split(s.t.root, blackHeight(s.t.root), i)
+		assert.Equal(t, blackHeight(l), hl)
+		assert.Equal(t, blackHeight(r), hr)
+
+		x, h := s.t.join(l, hl, s.t.newNode(&elem{v: -1, size: 1}), r, hr)
+		assert.Equal(t, blackHeight(x), h)
+		s.t.reset(x)
+		checkSeq(t, s, append(append(append([]interface{}{}, ref[:i]...), -1), ref[i:]...))
+	}
+}
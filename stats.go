@@ -0,0 +1,89 @@
+package rbtree
+
+import "unsafe"
+
+// Stats describes the shape of a tree.
+type Stats struct {
+	Size         int // number of nodes
+	Height       int // number of nodes on the longest path from the root to a leaf
+	BlackHeight  int // number of black nodes on any path from the root to a leaf
+	MinLeafDepth int // depth of the shallowest leaf, where the root has depth 0
+	MaxLeafDepth int // depth of the deepest leaf
+	RedNodes     int
+	BlackNodes   int
+	// AvgPathLength is the average number of nodes on the path from the
+	// root to a node, which is the cost of a successful search.
+	AvgPathLength float64
+	// MemoryBytes estimates memory used by the tree and its nodes,
+	// excluding memory referenced by payloads.
+	MemoryBytes uintptr
+}
+
+// Stats computes statistics of t in a single traversal. A leaf here is a
+// node without children. All fields but MemoryBytes are zero for an empty tree.
+func (t *Tree) Stats() Stats {
+	s := Stats{MemoryBytes: unsafe.Sizeof(*t)}
+	if t.root == nil {
+		return s
+	}
+	s.MinLeafDepth = -1
+	total := 0
+	var walk func(x *Node, depth, black int)
+	walk = func(x *Node, depth, black int) {
+		s.Size++
+		total += depth + 1
+		if x.color == RED {
+			s.RedNodes++
+		} else {
+			s.BlackNodes++
+			black++
+		}
+		if x.left == nil || x.right == nil {
+			s.BlackHeight = black
+		}
+		if x.left == nil && x.right == nil {
+			if s.MinLeafDepth < 0 || depth < s.MinLeafDepth {
+				s.MinLeafDepth = depth
+			}
+			if depth > s.MaxLeafDepth {
+				s.MaxLeafDepth = depth
+			}
+		}
+		if x.left != nil {
+			walk(x.left, depth+1, black)
+		}
+		if x.right != nil {
+			walk(x.right, depth+1, black)
+		}
+	}
+	walk(t.root, 0, 0)
+	s.Height = s.MaxLeafDepth + 1
+	s.AvgPathLength = float64(total) / float64(s.Size)
+	if t.hash != nil {
+		s.MemoryBytes += uintptr(s.Size) * unsafe.Sizeof(merkleNode{})
+	} else {
+		s.MemoryBytes += uintptr(s.Size) * unsafe.Sizeof(Node{})
+	}
+	return s
+}
+
+// Depth returns the number of edges between n and the root.
+func (n *Node) Depth() int {
+	d := 0
+	for x := n.p; x != nil; x = x.p {
+		d++
+	}
+	return d
+}
+
+// BlackHeight returns the number of black nodes on any path from n down
+// to a leaf, n self included. In a valid tree, it is the same for all paths.
+func (n *Node) BlackHeight() int {
+	h := 0
+	for x := n; x != nil; x = x.left {
+		if x.color == BLACK {
+			h++
+		}
+	}
+	return h
+}
@@ -0,0 +1,78 @@
+package rbtree
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	tr := New(CompareInt)
+	s := tr.Stats()
+	assert.Equal(t, Stats{MemoryBytes: unsafe.Sizeof(*tr)}, s)
+
+	tr.Insert(0)
+	s = tr.Stats()
+	assert.Equal(t, 1, s.Size)
+	assert.Equal(t, 1, s.Height)
+	assert.Equal(t, 1, s.BlackHeight)
+	assert.Equal(t, 0, s.MinLeafDepth)
+	assert.Equal(t, 0, s.MaxLeafDepth)
+	assert.Equal(t, 1, s.BlackNodes)
+	assert.Equal(t, 1.0, s.AvgPathLength)
+
+	n := 1 << 12
+	for i := 0; i < n; i++ {
+		tr.Insert(r.Intn(n))
+	}
+	s = tr.Stats()
+
+	// Compare against per-node helpers.
+	var size, red, maxDepth, total int
+	minDepth := n
+	tr.Walk(VisitFunc(func(x *Node) bool {
+		size++
+		d := x.Depth()
+		total += d + 1
+		if x.color == RED {
+			red++
+		}
+		if x.Left() == nil && x.Right() == nil {
+			if d < minDepth {
+				minDepth = d
+			}
+			if d > maxDepth {
+				maxDepth = d
+			}
+		}
+		return true
+	}))
+	assert.Equal(t, tr.Len(), s.Size)
+	assert.Equal(t, size, s.Size)
+	assert.Equal(t, red, s.RedNodes)
+	assert.Equal(t, size-red, s.BlackNodes)
+	assert.Equal(t, minDepth, s.MinLeafDepth)
+	assert.Equal(t, maxDepth, s.MaxLeafDepth)
+	assert.Equal(t, maxDepth+1, s.Height)
+	assert.Equal(t, tr.Root().BlackHeight(), s.BlackHeight)
+	assert.Equal(t, float64(total)/float64(size), s.AvgPathLength)
+	assert.Equal(t, unsafe.Sizeof(*tr)+uintptr(size)*unsafe.Sizeof(Node{}), s.MemoryBytes)
+
+	// Red-black trees are at most 2 log2(n+1) high.
+	assert.True(t, float64(s.Height) <= 2*math.Log2(float64(size+1)))
+	assert.True(t, s.MaxLeafDepth+1 <= 2*s.BlackHeight)
+
+	assert.Equal(t, 0, tr.Root().Depth())
+	tr.Walk(VisitFunc(func(x *Node) bool {
+		above := 0
+		for y := x.Parent(); y != nil; y = y.Parent() {
+			if y.color == BLACK {
+				above++
+			}
+		}
+		assert.Equal(t, s.BlackHeight, above+x.BlackHeight())
+		return true
+	}))
+}
@@ -1,5 +1,7 @@
 package rbtree
 
+import "errors"
+
 // BLACK and RED is the color of nodes
 const (
 	BLACK = false
@@ -15,9 +17,17 @@ type Node struct {
 
 // Tree is a red-black tree
 type Tree struct {
-	size    int
-	root    *Node
-	compare CompareFunc
+	size        int
+	root        *Node
+	first, last *Node // cached leftmost and rightmost nodes
+	compare     CompareFunc
+	observer    Observer
+	unobserved  CompareFunc // compare without notifying observer
+	hash        HashFunc    // only set for Merkle trees
+	// augment, if not nil, recomputes data of n aggregated over its subtree
+	// from its children. It's called bottom-up whenever the subtree changes.
+	augment func(n *Node)
+	subs    []*Subscription // subscribers of changes
 }
 
 // Left returns the left child of n
@@ -58,8 +68,19 @@ func (t *Tree) Len() int {
 
 // Clean resets a tree structure to it's initial state.
 func (t *Tree) Clean() *Tree {
+	var values []interface{}
+	if len(t.subs) != 0 {
+		values = make([]interface{}, 0, t.size)
+		for x := t.first; x != nil; x = t.Next(x) {
+			values = append(values, x.v)
+		}
+	}
 	t.size = 0
 	t.root = nil
+	t.first, t.last = nil, nil
+	if values != nil {
+		t.notifyClean(values)
+	}
 	return t
 }
 
@@ -75,10 +96,42 @@ func (t *Tree) Replace(n *Node, v interface{}) (interface{}, bool) {
 		return n.v, false
 	}
 	before := n.v
-	n.v = v
+	t.setValue(n, v)
 	return before, true
 }
 
+// ErrDuplicate is returned when a payload conflicts with an equal one
+// already in a tree.
+var ErrDuplicate = errors.New("rbtree: duplicate payload")
+
+// Rekey replaces the payload of n with v, which may compare differently,
+// and moves n to the position of v, so that n stays a valid handle.
+// If v still lies between the payloads of Prev(n) and Next(n), n is not
+// moved at all. If a payload equal to v is in t, other than in n, t is
+// left unchanged and ErrDuplicate is returned.
+func (t *Tree) Rekey(n *Node, v interface{}) error {
+	prev, next := t.Prev(n), t.Next(n)
+	if (prev == nil || t.compare(prev.v, v) < 0) &&
+		(next == nil || t.compare(v, next.v) < 0) {
+		t.setValue(n, v)
+		return nil
+	}
+	if t.search(t.root, v) != nil {
+		return ErrDuplicate
+	}
+	old := n.v
+	t.remove(n)
+	n.v = v
+	n.left, n.right = nil, nil
+	n.color = RED
+	_, p, cmp := t.locate(v)
+	t.attachNode(p, cmp, n)
+	if len(t.subs) != 0 {
+		t.notify(Change{Kind: Replaced, Old: old, New: v})
+	}
+	return nil
+}
+
 // Search tries to find the node containing payload v.
 // On success, the node containing v will be returned,
 // otherwise, nil will be returned to indicate the node is not found.
@@ -101,37 +154,218 @@ func (t *Tree) search(r *Node, v interface{}) *Node {
 	return nil
 }
 
+// LowerBound returns the first node whose payload is not less than v,
+// or nil if there is no such node.
+func (t *Tree) LowerBound(v interface{}) *Node {
+	var lb *Node
+	x := t.root
+	for x != nil {
+		if t.compare(v, x.v) > 0 {
+			x = x.right
+		} else {
+			lb, x = x, x.left
+		}
+	}
+	return lb
+}
+
+// UpperBound returns the first node whose payload is greater than v,
+// or nil if there is no such node.
+func (t *Tree) UpperBound(v interface{}) *Node {
+	var ub *Node
+	x := t.root
+	for x != nil {
+		if t.compare(v, x.v) < 0 {
+			ub, x = x, x.left
+		} else {
+			x = x.right
+		}
+	}
+	return ub
+}
+
+// EqualRange returns the half-open range [lo, hi) of nodes equal to v,
+// where a nil hi stands for the end of t. Since payloads in t are unique,
+// the range is either empty (lo == hi) or contains a single node:
+//
+//	for x := lo; x != hi; x = t.Next(x) { ... }
+func (t *Tree) EqualRange(v interface{}) (lo, hi *Node) {
+	lo = t.LowerBound(v)
+	if lo != nil && t.compare(v, lo.v) == 0 {
+		return lo, t.Next(lo)
+	}
+	return lo, lo
+}
+
+// Distance returns the number of Next steps from a to b, which is negative
+// if b precedes a. A nil node stands for the end of t, after the last node.
+// It walks from one node to the other, taking O(|distance|) time, so it is
+// no substitute for a rank query: nodes of a Tree carry no subtree counts.
+// Seq, whose nodes do, locates an index in O(log n) time.
+func (t *Tree) Distance(a, b *Node) int {
+	if a == b {
+		return 0
+	}
+	if a == nil || b != nil && t.compare(a.v, b.v) > 0 {
+		return -t.Distance(b, a)
+	}
+	d := 0
+	for x := a; x != b; x = t.Next(x) {
+		d++
+	}
+	return d
+}
+
 // Insert inserts v into correct place and returns a handle.
 // It will refuse to insert v when v is already in t, and returns the node.
 func (t *Tree) Insert(v interface{}) (*Node, bool) {
-	var cmp int
-	var p *Node
-	x := t.root
+	x, p, cmp := t.locate(v)
+	if x != nil {
+		// Disable duplicate v
+		return x, false
+	}
+	return t.attach(p, cmp, v), true
+}
+
+// Upsert inserts v if no equal payload is in t. Otherwise it replaces the
+// payload of the existing node with merge(old, v). The result of merge must
+// be equal to v, so that the node stays in place; otherwise Upsert panics,
+// leaving the node as it is.
+// The handle is returned, with a boolean value reporting whether v was inserted.
+func (t *Tree) Upsert(v interface{}, merge func(old, new interface{}) interface{}) (*Node, bool) {
+	x, p, cmp := t.locate(v)
+	if x != nil {
+		t.setEqual(x, v, merge(x.v, v))
+		return x, false
+	}
+	return t.attach(p, cmp, v), true
+}
+
+// GetOrInsert looks up the node equal to key. If it is not found,
+// make is called to compute the payload, which must be equal to key,
+// and the payload is inserted. The handle is returned, with a boolean value
+// reporting whether a new node was inserted.
+func (t *Tree) GetOrInsert(key interface{}, make func() interface{}) (*Node, bool) {
+	x, p, cmp := t.locate(key)
+	if x != nil {
+		return x, false
+	}
+	return t.attach(p, cmp, make()), true
+}
+
+// Update replaces the payload of the node equal to v with fn(old). The
+// result of fn must be equal to v; otherwise Update panics, leaving the node
+// as it is. It returns the node, or nil if v is not in t.
+func (t *Tree) Update(v interface{}, fn func(old interface{}) interface{}) *Node {
+	x := t.search(t.root, v)
+	if x != nil {
+		t.setEqual(x, v, fn(x.v))
+	}
+	return x
+}
+
+// setEqual replaces the payload of x, which is equal to v, with w. Like
+// Replace, it refuses w if it isn't equal to v, which would break the
+// order of t, but by panicking, as the callers can't report it.
+func (t *Tree) setEqual(x *Node, v, w interface{}) {
+	if t.compare(v, w) != 0 {
+		panic("rbtree: new payload is not equal to the old one")
+	}
+	t.setValue(x, w)
+}
 
+// locate descends from the root looking for v. If v is found, its node is
+// returned as x. Otherwise, x is nil, p is the parent under which v should
+// be attached and cmp is the result of comparing v with p.
+func (t *Tree) locate(v interface{}) (x, p *Node, cmp int) {
+	x = t.root
 	for x != nil {
-		p = x
 		if cmp = t.compare(v, x.v); cmp < 0 {
-			x = x.left
+			p, x = x, x.left
 		} else if cmp > 0 {
-			x = x.right
+			p, x = x, x.right
 		} else {
-			// Disable duplicate v
-			return x, false
+			return x, p, 0
 		}
 	}
+	return nil, p, cmp
+}
+
+// attach links a new node containing v as the left (cmp < 0) or right
+// child of p, which must have no child on that side, and rebalances t.
+func (t *Tree) attach(p *Node, cmp int, v interface{}) *Node {
+	n := t.attachNode(p, cmp, t.newNode(v))
+	if len(t.subs) != 0 {
+		t.notify(Change{Kind: Inserted, New: n.v})
+	}
+	return n
+}
+
+// insertNode links the detached node n back into t, so that n stays the
+// handle of its payload. If an equal payload is in t, n is not inserted
+// and the node of that payload is returned.
+func (t *Tree) insertNode(n *Node) (*Node, bool) {
+	x, p, cmp := t.locate(n.v)
+	if x != nil {
+		return x, false
+	}
+	n.left, n.right = nil, nil
+	n.color = RED
+	t.attachNode(p, cmp, n)
+	if len(t.subs) != 0 {
+		t.notify(Change{Kind: Inserted, New: n.v})
+	}
+	return n, true
+}
 
-	n := t.newNode(v)
+// attachNode is like attach, but links the given node, which must be red
+// and have no children.
+func (t *Tree) attachNode(p *Node, cmp int, n *Node) *Node {
 	n.p = p
+	if t.hash != nil {
+		n.merkle().own = t.digestOf(n.v)
+	}
 	if p == nil {
 		t.root = n
-	} else if cmp = t.compare(v, p.v); cmp < 0 {
+		t.first, t.last = n, n
+	} else if cmp < 0 {
 		p.left = n
+		if p == t.first {
+			t.first = n
+		}
 	} else {
 		p.right = n
+		if p == t.last {
+			t.last = n
+		}
 	}
+	t.augmentPath(n)
 	t.insertFix(n)
 	t.size++
-	return n, true
+	return n
+}
+
+// setValue replaces the payload of n in place with v.
+func (t *Tree) setValue(n *Node, v interface{}) {
+	old := n.v
+	n.v = v
+	if t.hash != nil {
+		n.merkle().own = t.digestOf(v)
+	}
+	t.augmentPath(n)
+	if len(t.subs) != 0 {
+		t.notify(Change{Kind: Replaced, Old: old, New: v})
+	}
+}
+
+// augmentPath recomputes augmented data from x up to the root.
+func (t *Tree) augmentPath(x *Node) {
+	if t.augment == nil {
+		return
+	}
+	for ; x != nil; x = x.p {
+		t.augment(x)
+	}
 }
 
 // DeleteValue deletes the node whose payload is equal to v.
@@ -145,11 +379,27 @@ func (t *Tree) DeleteValue(v interface{}) (interface{}, bool) {
 
 // Delete removes x from t and returns its payload.
 func (t *Tree) Delete(x *Node) interface{} {
+	t.remove(x)
+	if len(t.subs) != 0 {
+		t.notify(Change{Kind: Deleted, Old: x.v})
+	}
+	return x.v
+}
+
+// remove unlinks x from t.
+func (t *Tree) remove(x *Node) {
 	// z is the node that is MOVED to a new place,
 	// and color is the color of the node previously in this place.
 	var z, p *Node
 	color := x.color
 
+	if x == t.first {
+		t.first = t.Next(x)
+	}
+	if x == t.last {
+		t.last = t.Prev(x)
+	}
+
 	if x.left == nil {
 		z, p = x.right, x.p
 		t.transplant(x, x.right)
@@ -212,9 +462,46 @@ func (t *Tree) Delete(x *Node) interface{} {
 		t.transplant(x, y)
 		y.color = x.color
 	}
+	// p is the lowest node whose subtree has changed.
+	t.augmentPath(p)
 	if color == BLACK {
 		t.deleteFix(p, z)
 	}
 	t.size--
-	return x.v
+}
+
+// PeekFirst returns the minimum payload in t without removing it.
+// If t is empty, it returns nil and false.
+func (t *Tree) PeekFirst() (interface{}, bool) {
+	if t.first == nil {
+		return nil, false
+	}
+	return t.first.v, true
+}
+
+// PeekLast returns the maximum payload in t without removing it.
+// If t is empty, it returns nil and false.
+func (t *Tree) PeekLast() (interface{}, bool) {
+	if t.last == nil {
+		return nil, false
+	}
+	return t.last.v, true
+}
+
+// PopFirst removes the minimum node from t and returns its payload.
+// If t is empty, it returns nil and false.
+func (t *Tree) PopFirst() (interface{}, bool) {
+	if t.first == nil {
+		return nil, false
+	}
+	return t.Delete(t.first), true
+}
+
+// PopLast removes the maximum node from t and returns its payload.
+// If t is empty, it returns nil and false.
+func (t *Tree) PopLast() (interface{}, bool) {
+	if t.last == nil {
+		return nil, false
+	}
+	return t.Delete(t.last), true
 }
@@ -72,6 +72,222 @@ func TestTree(t *testing.T) {
 	}
 }
 
+func TestPop(t *testing.T) {
+	n := 1 << 10
+	tr := New(CompareInt)
+
+	_, ok := tr.PeekFirst()
+	assert.False(t, ok)
+	_, ok = tr.PeekLast()
+	assert.False(t, ok)
+	_, ok = tr.PopFirst()
+	assert.False(t, ok)
+	_, ok = tr.PopLast()
+	assert.False(t, ok)
+
+	for _, i := range r.Perm(n) {
+		tr.Insert(i)
+	}
+	v, ok := tr.PeekFirst()
+	assert.True(t, ok)
+	assert.Equal(t, 0, v)
+	v, ok = tr.PeekLast()
+	assert.True(t, ok)
+	assert.Equal(t, n-1, v)
+
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		v, ok = tr.PopFirst()
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+		v, ok = tr.PopLast()
+		assert.True(t, ok)
+		assert.Equal(t, j, v)
+	}
+	assert.True(t, tr.IsEmpty())
+	assert.Nil(t, tr.First())
+	assert.Nil(t, tr.Last())
+
+	// The cached extremes must survive arbitrary deletions.
+	for i := 0; i < n; i++ {
+		tr.Insert(r.Intn(n))
+		if i%3 == 0 {
+			tr.DeleteValue(r.Intn(n))
+		}
+		first, last := tr.Root(), tr.Root()
+		for first != nil && first.Left() != nil {
+			first = first.Left()
+		}
+		for last != nil && last.Right() != nil {
+			last = last.Right()
+		}
+		assert.Equal(t, first, tr.First())
+		assert.Equal(t, last, tr.Last())
+	}
+}
+
+type pair struct {
+	key, count int
+}
+
+func comparePair(x, y interface{}) int {
+	return compareInt(x.(*pair).key, y.(*pair).key)
+}
+
+func TestUpsert(t *testing.T) {
+	tr := New(comparePair)
+	merge := func(old, new interface{}) interface{} {
+		old.(*pair).count += new.(*pair).count
+		return old
+	}
+
+	keys := []int{3, 1, 3, 2, 3, 1}
+	inserted := []bool{true, true, false, true, false, false}
+	for i, k := range keys {
+		n, ok := tr.Upsert(&pair{k, 1}, merge)
+		assert.Equal(t, inserted[i], ok)
+		assert.Equal(t, k, n.Value().(*pair).key)
+	}
+	assert.Equal(t, 3, tr.Len())
+	assert.Equal(t, 2, tr.Search(&pair{key: 1}).Value().(*pair).count)
+	assert.Equal(t, 1, tr.Search(&pair{key: 2}).Value().(*pair).count)
+	assert.Equal(t, 3, tr.Search(&pair{key: 3}).Value().(*pair).count)
+
+	calls := 0
+	mk := func() interface{} {
+		calls++
+		return &pair{4, 0}
+	}
+	n, ok := tr.GetOrInsert(&pair{key: 4}, mk)
+	assert.True(t, ok)
+	assert.Equal(t, 4, n.Value().(*pair).key)
+	m, ok := tr.GetOrInsert(&pair{key: 4}, mk)
+	assert.False(t, ok)
+	assert.Equal(t, n, m)
+	assert.Equal(t, 1, calls)
+
+	n = tr.Update(&pair{key: 4}, func(old interface{}) interface{} {
+		return &pair{4, 10}
+	})
+	assert.NotNil(t, n)
+	assert.Equal(t, 10, tr.Search(&pair{key: 4}).Value().(*pair).count)
+	assert.Nil(t, tr.Update(&pair{key: 5}, func(old interface{}) interface{} {
+		t.Fatal("fn called for missing value")
+		return old
+	}))
+	assert.Equal(t, 4, tr.Len())
+	assert.True(t, checkRbTree(tr))
+
+	// Payloads that would move are refused.
+	assert.Panics(t, func() {
+		tr.Upsert(&pair{1, 1}, func(old, new interface{}) interface{} { return &pair{9, 0} })
+	})
+	assert.Panics(t, func() {
+		tr.Update(&pair{key: 1}, func(old interface{}) interface{} { return &pair{0, 0} })
+	})
+	assert.Equal(t, 2, tr.Search(&pair{key: 1}).Value().(*pair).count)
+	assert.Nil(t, tr.Search(&pair{key: 9}))
+	assert.True(t, checkRbTree(tr))
+}
+
+func TestBound(t *testing.T) {
+	n := 1 << 8
+	tr := New(CompareInt)
+	assert.Nil(t, tr.LowerBound(0))
+	assert.Nil(t, tr.UpperBound(0))
+	lo, hi := tr.EqualRange(0)
+	assert.Nil(t, lo)
+	assert.Nil(t, hi)
+
+	// Even values only.
+	for i := 0; i < n; i += 2 {
+		tr.Insert(i)
+	}
+	for v := -1; v <= n; v++ {
+		// Reference bounds by linear search.
+		var lb, ub *Node
+		for x := tr.Last(); x != nil; x = tr.Prev(x) {
+			if x.Value().(int) >= v {
+				lb = x
+			}
+			if x.Value().(int) > v {
+				ub = x
+			}
+		}
+		assert.Equal(t, lb, tr.LowerBound(v), "LowerBound(%d)", v)
+		assert.Equal(t, ub, tr.UpperBound(v), "UpperBound(%d)", v)
+
+		lo, hi := tr.EqualRange(v)
+		count := 0
+		for x := lo; x != hi; x = tr.Next(x) {
+			assert.Equal(t, v, x.Value())
+			count++
+		}
+		if v >= 0 && v < n && v%2 == 0 {
+			assert.Equal(t, 1, count)
+		} else {
+			assert.Equal(t, 0, count)
+		}
+		assert.Equal(t, count, tr.Distance(lo, hi))
+	}
+
+	for i := 0; i < 64; i++ {
+		a, b := r.Intn(n/2), r.Intn(n/2)
+		x, y := tr.Search(2*a), tr.Search(2*b)
+		assert.Equal(t, b-a, tr.Distance(x, y))
+		assert.Equal(t, n/2-a, tr.Distance(x, nil))
+		assert.Equal(t, a-n/2, tr.Distance(nil, x))
+	}
+	assert.Equal(t, 0, tr.Distance(nil, nil))
+	assert.Equal(t, tr.Len(), tr.Distance(tr.First(), nil))
+}
+
+func TestRekey(t *testing.T) {
+	n := 1 << 8
+	tr := New(CompareInt)
+	handles := make(map[int]*Node)
+	for i := 0; i < n; i++ {
+		handles[i*4], _ = tr.Insert(i * 4)
+	}
+	var ops Counters
+	tr.SetObserver(&ops)
+
+	// Staying between neighbours doesn't move the node.
+	x := handles[40]
+	assert.NoError(t, tr.Rekey(x, 41))
+	assert.Zero(t, ops.Count(OpLeftRotate)+ops.Count(OpRightRotate)+ops.Count(OpInsertFix))
+	assert.Equal(t, 41, x.Value())
+	delete(handles, 40)
+	handles[41] = x
+
+	assert.Equal(t, ErrDuplicate, tr.Rekey(x, 80))
+	assert.Equal(t, 41, x.Value())
+	assert.Equal(t, x, tr.Search(41))
+
+	for i := 0; i < 4*n; i++ {
+		var k int
+		for k = range handles {
+			break
+		}
+		x := handles[k]
+		v := r.Intn(8 * n)
+		if err := tr.Rekey(x, v); err != nil {
+			assert.Equal(t, ErrDuplicate, err)
+			_, ok := handles[v]
+			assert.True(t, ok)
+			continue
+		}
+		delete(handles, k)
+		handles[v] = x
+	}
+	assert.Equal(t, n, tr.Len())
+	assert.True(t, checkRbTree(tr))
+	assert.Equal(t, tr.Len(), tr.Distance(tr.First(), nil))
+	for k, x := range handles {
+		assert.Equal(t, k, x.Value())
+		assert.Equal(t, x, tr.Search(k))
+	}
+}
+
 func BenchmarkInsert(b *testing.B) {
 	tr := New(CompareInt)
 	b.ResetTimer()
@@ -102,3 +318,14 @@ func BenchmarkDelete(b *testing.B) {
 		tr.DeleteValue(v)
 	}
 }
+
+func BenchmarkPopFirst(b *testing.B) {
+	tr := New(CompareInt)
+	for i := 0; i < b.N; i++ {
+		tr.Insert(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tr.PopFirst()
+	}
+}
@@ -0,0 +1,177 @@
+package rbtree
+
+import "errors"
+
+// ErrTxDone is returned by Commit, Rollback and RollbackTo on a transaction
+// that has already been committed or rolled back. Other methods of Tx, which
+// return no error, panic with ErrTxDone instead.
+var ErrTxDone = errors.New("rbtree: transaction has already been committed or rolled back")
+
+// ErrTxConflict is returned by Rollback and RollbackTo when a modification
+// can't be undone, because the tree was modified outside the transaction.
+var ErrTxConflict = errors.New("rbtree: tree was modified outside the transaction")
+
+// Tx is a transaction on a tree. Its modifications are applied to the tree
+// immediately, so that reads, through the transaction or the tree, see them,
+// and recorded in an undo log, so that they can be rolled back. Rolling back
+// restores the exact previous contents, including the handles of deleted
+// nodes, which become valid again. Nodes inserted by the transaction become
+// invalid.
+//
+// The tree must not be modified other than through the transaction
+// until it is done, and a Tx is not safe for concurrent use.
+type Tx struct {
+	t    *Tree
+	undo []undo
+	done bool
+}
+
+// Savepoint marks a state of a transaction to roll back to.
+type Savepoint int
+
+type undo struct {
+	op byte // one of the tx constants
+	n  *Node
+	v  interface{} // previous payload of a replaced or rekeyed node
+}
+
+const (
+	txInsert byte = iota
+	txDelete
+	txReplace
+	txRekey
+)
+
+// Begin starts a transaction on t.
+func (t *Tree) Begin() *Tx {
+	return &Tx{t: t}
+}
+
+// Tree returns the tree of tx.
+func (tx *Tx) Tree() *Tree { return tx.t }
+
+// Search is the same as Tree.Search.
+func (tx *Tx) Search(v interface{}) *Node { return tx.t.Search(v) }
+
+// Has is the same as Tree.Has.
+func (tx *Tx) Has(v interface{}) bool { return tx.t.Has(v) }
+
+// Insert is like Tree.Insert, but records the insertion.
+// Like other modifications, it panics with ErrTxDone if tx is done.
+func (tx *Tx) Insert(v interface{}) (*Node, bool) {
+	tx.check()
+	n, ok := tx.t.Insert(v)
+	if ok {
+		tx.undo = append(tx.undo, undo{op: txInsert, n: n})
+	}
+	return n, ok
+}
+
+// Delete is like Tree.Delete, but records the deletion.
+func (tx *Tx) Delete(n *Node) interface{} {
+	tx.check()
+	v := tx.t.Delete(n)
+	tx.undo = append(tx.undo, undo{op: txDelete, n: n})
+	return v
+}
+
+// DeleteValue is like Tree.DeleteValue, but records the deletion.
+func (tx *Tx) DeleteValue(v interface{}) (interface{}, bool) {
+	tx.check()
+	if x := tx.t.Search(v); x != nil {
+		return tx.Delete(x), true
+	}
+	return nil, false
+}
+
+// Replace is like Tree.Replace, but records the replacement.
+func (tx *Tx) Replace(n *Node, v interface{}) (interface{}, bool) {
+	tx.check()
+	before, ok := tx.t.Replace(n, v)
+	if ok {
+		tx.undo = append(tx.undo, undo{op: txReplace, n: n, v: before})
+	}
+	return before, ok
+}
+
+// Rekey is like Tree.Rekey, but records the change of key.
+func (tx *Tx) Rekey(n *Node, v interface{}) error {
+	tx.check()
+	before := n.v
+	if err := tx.t.Rekey(n, v); err != nil {
+		return err
+	}
+	tx.undo = append(tx.undo, undo{op: txRekey, n: n, v: before})
+	return nil
+}
+
+// Savepoint returns the current state of tx, which can be restored by
+// RollbackTo. Savepoints nest: rolling back to one invalidates the
+// savepoints taken after it.
+func (tx *Tx) Savepoint() Savepoint {
+	tx.check()
+	return Savepoint(len(tx.undo))
+}
+
+// RollbackTo undoes modifications made since sp was taken, from the latest
+// one. The transaction stays open. If a modification can't be undone, it
+// stops there and returns ErrTxConflict: the modifications up to that one
+// stay in the tree and in the transaction.
+func (tx *Tx) RollbackTo(sp Savepoint) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	if sp < 0 || int(sp) > len(tx.undo) {
+		return errors.New("rbtree: invalid savepoint")
+	}
+	for i := len(tx.undo) - 1; i >= int(sp); i-- {
+		u := tx.undo[i]
+		if u.op != txDelete && tx.t.search(tx.t.root, u.n.v) != u.n {
+			// The node has been removed.
+			return ErrTxConflict
+		}
+		switch u.op {
+		case txInsert:
+			tx.t.Delete(u.n)
+		case txDelete:
+			if _, ok := tx.t.insertNode(u.n); !ok {
+				return ErrTxConflict
+			}
+		case txReplace:
+			tx.t.setValue(u.n, u.v)
+		case txRekey:
+			if tx.t.Rekey(u.n, u.v) != nil {
+				return ErrTxConflict
+			}
+		}
+		tx.undo[i] = undo{}
+		tx.undo = tx.undo[:i]
+	}
+	return nil
+}
+
+// Rollback undoes all modifications of tx and ends it. If it fails, tx
+// stays open, like after a failed RollbackTo.
+func (tx *Tx) Rollback() error {
+	if err := tx.RollbackTo(0); err != nil {
+		return err
+	}
+	tx.done = true
+	return nil
+}
+
+// Commit keeps all modifications of tx and ends it.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.undo = nil
+	tx.done = true
+	return nil
+}
+
+func (tx *Tx) check() {
+	if tx.done {
+		panic(ErrTxDone)
+	}
+}
@@ -0,0 +1,168 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func snapshot(tr *Tree) []interface{} {
+	var vs []interface{}
+	tr.Walk(VisitFunc(func(x *Node) bool {
+		vs = append(vs, x.Value())
+		return true
+	}))
+	return vs
+}
+
+// randomTx applies random modifications through tx.
+func randomTx(tx *Tx, n, ops int) {
+	for i := 0; i < ops; i++ {
+		k := r.Intn(n)
+		switch r.Intn(4) {
+		case 0:
+			tx.Insert(&pair{k, i})
+		case 1:
+			tx.DeleteValue(&pair{key: k})
+		case 2:
+			if x := tx.Search(&pair{key: k}); x != nil {
+				tx.Replace(x, &pair{k, -i})
+			}
+		case 3:
+			if x := tx.Search(&pair{key: k}); x != nil {
+				tx.Rekey(x, &pair{r.Intn(n), i})
+			}
+		}
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	n := 256
+	tr := NewMerkle(comparePair, func(v interface{}) uint64 {
+		p := v.(*pair)
+		return uint64(p.key)<<32 | uint64(uint32(p.count))
+	})
+	for i := 0; i < n; i += 2 {
+		tr.Insert(&pair{i, 0})
+	}
+	handles := make(map[*Node]interface{})
+	tr.Walk(VisitFunc(func(x *Node) bool {
+		handles[x] = x.Value()
+		return true
+	}))
+	before, hash := snapshot(tr), tr.RootHash()
+
+	for round := 0; round < 20; round++ {
+		tx := tr.Begin()
+		assert.Equal(t, tr, tx.Tree())
+		randomTx(tx, n, 200)
+		assert.NoError(t, tx.Rollback())
+
+		assert.Equal(t, before, snapshot(tr))
+		assert.Equal(t, hash, tr.RootHash())
+		assert.True(t, checkRbTree(tr))
+		checkDigest(t, tr, tr.root)
+		// Handles of deleted nodes are valid again.
+		for x, v := range handles {
+			assert.Equal(t, x, tr.Search(v))
+		}
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	tr := New(comparePair)
+	tx := tr.Begin()
+	x, ok := tx.Insert(&pair{1, 1})
+	assert.True(t, ok)
+	_, ok = tx.Insert(&pair{1, 2})
+	assert.False(t, ok)
+	// Reads see writes of the transaction.
+	assert.True(t, tx.Has(&pair{key: 1}))
+	assert.Equal(t, x, tr.Search(&pair{key: 1}))
+	_, ok = tx.Replace(x, &pair{2, 0})
+	assert.False(t, ok)
+	assert.NoError(t, tx.Commit())
+	assert.Equal(t, []interface{}{&pair{1, 1}}, snapshot(tr))
+
+	assert.Equal(t, ErrTxDone, tx.Commit())
+	assert.Equal(t, ErrTxDone, tx.Rollback())
+	assert.Equal(t, ErrTxDone, tx.RollbackTo(0))
+	assert.Panics(t, func() { tx.Insert(&pair{3, 3}) })
+}
+
+func TestTxSavepoint(t *testing.T) {
+	n := 64
+	tr := New(comparePair)
+	for i := 0; i < n; i++ {
+		tr.Insert(&pair{i, 0})
+	}
+	tx := tr.Begin()
+
+	var states [][]interface{}
+	var sps []Savepoint
+	for i := 0; i < 5; i++ {
+		states = append(states, snapshot(tr))
+		sps = append(sps, tx.Savepoint())
+		randomTx(tx, n, 30)
+	}
+	final := snapshot(tr)
+
+	// Roll back savepoints from the innermost one.
+	for i := len(sps) - 1; i >= 2; i-- {
+		assert.NoError(t, tx.RollbackTo(sps[i]))
+		assert.Equal(t, states[i], snapshot(tr))
+	}
+	assert.Error(t, tx.RollbackTo(sps[4]))
+	assert.Error(t, tx.RollbackTo(-1))
+
+	// The transaction goes on after a partial rollback.
+	tx.Insert(&pair{n, 0})
+	assert.NoError(t, tx.Commit())
+	assert.True(t, tr.Has(&pair{key: n}))
+	assert.Equal(t, len(states[2])+1, tr.Len())
+	assert.NotEqual(t, final, snapshot(tr))
+	assert.True(t, checkRbTree(tr))
+}
+
+func TestTxConflict(t *testing.T) {
+	tr := New(CompareInt)
+	tr.Insert(1)
+	tr.Insert(2)
+	tx := tr.Begin()
+	tx.DeleteValue(1)
+	tx.Insert(3)
+	tx.DeleteValue(2)
+
+	// Modify the tree outside the transaction.
+	tr.Insert(1)
+	tr.DeleteValue(3)
+
+	// 2 is restored, 3 is gone already.
+	assert.Equal(t, ErrTxConflict, tx.Rollback())
+	assert.Equal(t, []interface{}{1, 2}, snapshot(tr))
+	assert.True(t, checkRbTree(tr))
+
+	// The transaction stays open, with the modifications not undone.
+	assert.Equal(t, ErrTxConflict, tx.Rollback())
+	assert.Equal(t, []interface{}{1, 2}, snapshot(tr))
+	assert.NoError(t, tx.Commit())
+}
+
+func TestTxConflictDelete(t *testing.T) {
+	tr := New(CompareInt)
+	tr.Insert(1)
+	tx := tr.Begin()
+	tx.DeleteValue(1)
+	sp := tx.Savepoint()
+	tx.Insert(2)
+
+	tr.Insert(1) // takes the place of the deleted node
+	assert.Equal(t, ErrTxConflict, tx.Rollback())
+	assert.Equal(t, []interface{}{1}, snapshot(tr))
+	assert.NoError(t, tx.RollbackTo(sp)) // undone up to sp already
+
+	tr.DeleteValue(1)
+	assert.NoError(t, tx.Rollback())
+	assert.Equal(t, []interface{}{1}, snapshot(tr))
+	assert.Equal(t, ErrTxDone, tx.Rollback())
+}
@@ -48,15 +48,14 @@ func (t *Tree) WalkPostorder(v Visitor) {
 	}
 }
 
-// WalkSubPostorder traverses subtree rooted at x in post-order, x self is also visited.
-func (t *Tree) WalkSubPostorder(v Visitor, x *Node) {
-	for n := t.PostorderFirstNode(x); n != x; n = t.PostorderNext(n) {
-		v = v.Visit(n)
+// WalkPostorderReverse traverses t in reverse post-order, which means that a node is encountered before its children, and right children before left ones.
+func (t *Tree) WalkPostorderReverse(v Visitor) {
+	for x := t.PostorderLast(); x != nil; x = t.PostorderPrev(x) {
+		v = v.Visit(x)
 		if v == nil {
 			return
 		}
 	}
-	v.Visit(x)
 }
 
 // WalkPreorder traverses t in pre-order, which means that a node is encountered before its children.
@@ -69,14 +68,82 @@ func (t *Tree) WalkPreorder(v Visitor) {
 	}
 }
 
+// WalkPreorderReverse traverses t in reverse pre-order, which means that a node is encountered after its children, and right children before left ones.
+func (t *Tree) WalkPreorderReverse(v Visitor) {
+	for x := t.PreorderLast(); x != nil; x = t.PreorderPrev(x) {
+		v = v.Visit(x)
+		if v == nil {
+			return
+		}
+	}
+}
+
+// WalkLevelOrder traverses t in level-order (breadth-first), which means that
+// nodes are encountered by increasing depth, and from left to right within a level.
+func (t *Tree) WalkLevelOrder(v Visitor) {
+	t.walkIter(v, t.Iter(t.root, LevelOrder))
+}
+
+// LevelFunc is invoked for each level of a tree with its depth, which is 0 for
+// the root, and its nodes from left to right. The slice is only valid during
+// the call. If it returns false, tree traversal will stop.
+type LevelFunc func(depth int, level []*Node) bool
+
+// WalkLevels traverses t in level-order one level at a time.
+func (t *Tree) WalkLevels(f LevelFunc) {
+	if t.root == nil {
+		return
+	}
+	level, next := []*Node{t.root}, []*Node(nil)
+	for depth := 0; len(level) > 0; depth++ {
+		if !f(depth, level) {
+			return
+		}
+		next = next[:0]
+		for _, x := range level {
+			if x.left != nil {
+				next = append(next, x.left)
+			}
+			if x.right != nil {
+				next = append(next, x.right)
+			}
+		}
+		level, next = next, level
+	}
+}
+
+// WalkSub traverses subtree rooted at x in in-order, x self is also visited.
+// Like other subtree walks, it does nothing if x is nil, and stops as soon
+// as the visitor returns nil.
+func (t *Tree) WalkSub(v Visitor, x *Node) {
+	t.walkIter(v, t.Iter(x, InOrder))
+}
+
+// WalkSubReverse traverses subtree rooted at x in descend order of values, x self is also visited.
+func (t *Tree) WalkSubReverse(v Visitor, x *Node) {
+	t.walkIter(v, t.Iter(x, ReverseOrder))
+}
+
 // WalkSubPreorder traverses subtree rooted at x in pre-order, x self is also visited.
 func (t *Tree) WalkSubPreorder(v Visitor, x *Node) {
-	var n *Node
-	for n = t.PreorderLastNode(x); x != n; x = t.PreorderNext(x) {
+	t.walkIter(v, t.Iter(x, PreOrder))
+}
+
+// WalkSubPostorder traverses subtree rooted at x in post-order, x self is also visited.
+func (t *Tree) WalkSubPostorder(v Visitor, x *Node) {
+	t.walkIter(v, t.Iter(x, PostOrder))
+}
+
+// WalkSubLevelOrder traverses subtree rooted at x in level-order, x self is also visited.
+func (t *Tree) WalkSubLevelOrder(v Visitor, x *Node) {
+	t.walkIter(v, t.Iter(x, LevelOrder))
+}
+
+func (t *Tree) walkIter(v Visitor, it *Iterator) {
+	for x := it.Next(); x != nil; x = it.Next() {
 		v = v.Visit(x)
 		if v == nil {
 			return
 		}
 	}
-	v.Visit(n)
 }
@@ -41,6 +41,8 @@ func TestWalk(t *testing.T) {
 	}
 	tr.WalkPostorder(VisitFunc(fn))
 	tr.WalkPreorder(VisitFunc(fn))
+	tr.WalkPostorderReverse(VisitFunc(fn))
+	tr.WalkPreorderReverse(VisitFunc(fn))
 
 	size := 0
 	fn = func(x *Node) bool {
@@ -62,6 +64,14 @@ func TestWalk(t *testing.T) {
 	tr.WalkSubPreorder(VisitFunc(fn), tr.Root())
 	assert.Equal(t, n, size)
 
+	size = 0
+	tr.WalkPostorderReverse(VisitFunc(fn))
+	assert.Equal(t, n, size)
+
+	size = 0
+	tr.WalkPreorderReverse(VisitFunc(fn))
+	assert.Equal(t, n, size)
+
 	size = 0
 	fn = func(x *Node) bool {
 		size++
@@ -73,5 +83,147 @@ func TestWalk(t *testing.T) {
 	tr.WalkPreorder(VisitFunc(fn))
 	tr.WalkSubPostorder(VisitFunc(fn), tr.Root())
 	tr.WalkSubPreorder(VisitFunc(fn), tr.Root())
-	assert.Equal(t, 6, size)
+	tr.WalkPostorderReverse(VisitFunc(fn))
+	tr.WalkPreorderReverse(VisitFunc(fn))
+	assert.Equal(t, 8, size)
+}
+
+func TestWalkLevelOrder(t *testing.T) {
+	n := 1 << 10
+	tr := New(CompareInt)
+	tr.WalkLevelOrder(VisitFunc(func(x *Node) bool {
+		t.Fatal("visited empty tree")
+		return true
+	}))
+	tr.WalkLevels(func(depth int, level []*Node) bool {
+		t.Fatal("visited empty tree")
+		return true
+	})
+	for i := 0; i < n; i++ {
+		tr.Insert(r.Intn(n))
+	}
+
+	// Reference: nodes grouped by depth from a recursive pre-order walk.
+	var want [][]*Node
+	var collect func(x *Node, depth int)
+	collect = func(x *Node, depth int) {
+		if x == nil {
+			return
+		}
+		if depth == len(want) {
+			want = append(want, nil)
+		}
+		want[depth] = append(want[depth], x)
+		collect(x.Left(), depth+1)
+		collect(x.Right(), depth+1)
+	}
+	collect(tr.Root(), 0)
+
+	var got []*Node
+	tr.WalkLevelOrder(VisitFunc(func(x *Node) bool {
+		got = append(got, x)
+		return true
+	}))
+	var flat []*Node
+	for _, level := range want {
+		flat = append(flat, level...)
+	}
+	assert.Equal(t, flat, got)
+
+	var levels [][]*Node
+	tr.WalkLevels(func(depth int, level []*Node) bool {
+		assert.Equal(t, len(levels), depth)
+		levels = append(levels, append([]*Node(nil), level...))
+		return true
+	})
+	assert.Equal(t, want, levels)
+
+	size := 0
+	tr.WalkLevelOrder(VisitFunc(func(x *Node) bool {
+		size++
+		return size < 3
+	}))
+	assert.Equal(t, 3, size)
+	size = 0
+	tr.WalkLevels(func(depth int, level []*Node) bool {
+		size++
+		return false
+	})
+	assert.Equal(t, 1, size)
+}
+
+// refOrder lists nodes of the subtree rooted at x in order o recursively.
+func refOrder(x *Node, o Order) []*Node {
+	if x == nil {
+		return nil
+	}
+	var ns []*Node
+	switch o {
+	case InOrder:
+		ns = append(ns, refOrder(x.left, o)...)
+		ns = append(ns, x)
+		ns = append(ns, refOrder(x.right, o)...)
+	case ReverseOrder:
+		ns = append(ns, refOrder(x.right, o)...)
+		ns = append(ns, x)
+		ns = append(ns, refOrder(x.left, o)...)
+	case PreOrder:
+		ns = append(ns, x)
+		ns = append(ns, refOrder(x.left, o)...)
+		ns = append(ns, refOrder(x.right, o)...)
+	case PostOrder:
+		ns = append(ns, refOrder(x.left, o)...)
+		ns = append(ns, refOrder(x.right, o)...)
+		ns = append(ns, x)
+	case LevelOrder:
+		for level := []*Node{x}; len(level) > 0; {
+			var next []*Node
+			for _, y := range level {
+				for _, c := range []*Node{y.left, y.right} {
+					if c != nil {
+						next = append(next, c)
+					}
+				}
+			}
+			ns = append(ns, level...)
+			level = next
+		}
+	}
+	return ns
+}
+
+func TestWalkSub(t *testing.T) {
+	walks := map[Order]func(tr *Tree, v Visitor, x *Node){
+		InOrder:      (*Tree).WalkSub,
+		ReverseOrder: (*Tree).WalkSubReverse,
+		PreOrder:     (*Tree).WalkSubPreorder,
+		PostOrder:    (*Tree).WalkSubPostorder,
+		LevelOrder:   (*Tree).WalkSubLevelOrder,
+	}
+	for n := 0; n <= 40; n++ {
+		tr := New(CompareInt)
+		for _, i := range r.Perm(n) {
+			tr.Insert(i)
+		}
+		roots := append(refOrder(tr.Root(), InOrder), nil)
+		for _, x := range roots {
+			for o, walk := range walks {
+				want := refOrder(x, o)
+				// Stop after every possible number of visits,
+				// including never.
+				for stop := 1; stop <= len(want)+1; stop++ {
+					var got []*Node
+					walk(tr, VisitFunc(func(y *Node) bool {
+						got = append(got, y)
+						return len(got) < stop
+					}), x)
+					if stop <= len(want) {
+						assert.Equal(t, want[:stop], got)
+					} else {
+						assert.Equal(t, want, got)
+					}
+				}
+			}
+		}
+	}
 }
@@ -0,0 +1,95 @@
+package rbtree
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrVisitor is like Visitor, but Visit can fail. If it returns a non-nil
+// error, tree traversal will stop and the walk returns the error.
+type ErrVisitor interface {
+	Visit(n *Node) (w ErrVisitor, err error)
+}
+
+// StopWalk can be returned by an ErrVisitFunc to stop tree traversal
+// without failing the walk.
+var StopWalk = errors.New("rbtree: stop walk")
+
+// ErrVisitFunc is invoked for nodes. If it returns StopWalk, tree traversal
+// will stop; if it returns any other non-nil error, the walk fails with it.
+type ErrVisitFunc func(n *Node) error
+
+// Visit implements the ErrVisitor interface
+func (f ErrVisitFunc) Visit(n *Node) (w ErrVisitor, err error) {
+	if err = f(n); err == nil {
+		return f, nil
+	} else if err == StopWalk {
+		return nil, nil
+	}
+	return nil, err
+}
+
+// ctxCheckInterval is the number of nodes visited between checks of ctx.Done().
+const ctxCheckInterval = 64
+
+// WalkContext is like Walk, but stops when ctx is done, in which case it
+// returns ctx.Err(). Otherwise it returns the error of the visitor, if any.
+func (t *Tree) WalkContext(ctx context.Context, v ErrVisitor) error {
+	return walkContext(ctx, v, t.First(), t.Next)
+}
+
+// WalkReverseContext is the cancellable version of WalkReverse.
+func (t *Tree) WalkReverseContext(ctx context.Context, v ErrVisitor) error {
+	return walkContext(ctx, v, t.Last(), t.Prev)
+}
+
+// WalkPostorderContext is the cancellable version of WalkPostorder.
+func (t *Tree) WalkPostorderContext(ctx context.Context, v ErrVisitor) error {
+	return walkContext(ctx, v, t.PostorderFirst(), t.PostorderNext)
+}
+
+// WalkPostorderReverseContext is the cancellable version of WalkPostorderReverse.
+func (t *Tree) WalkPostorderReverseContext(ctx context.Context, v ErrVisitor) error {
+	return walkContext(ctx, v, t.PostorderLast(), t.PostorderPrev)
+}
+
+// WalkPreorderContext is the cancellable version of WalkPreorder.
+func (t *Tree) WalkPreorderContext(ctx context.Context, v ErrVisitor) error {
+	return walkContext(ctx, v, t.PreorderFirst(), t.PreorderNext)
+}
+
+// WalkPreorderReverseContext is the cancellable version of WalkPreorderReverse.
+func (t *Tree) WalkPreorderReverseContext(ctx context.Context, v ErrVisitor) error {
+	return walkContext(ctx, v, t.PreorderLast(), t.PreorderPrev)
+}
+
+// WalkLevelOrderContext is the cancellable version of WalkLevelOrder.
+func (t *Tree) WalkLevelOrderContext(ctx context.Context, v ErrVisitor) error {
+	return t.WalkSubContext(ctx, v, t.root, LevelOrder)
+}
+
+// WalkSubContext traverses subtree rooted at x in order o, x self is also
+// visited. It is the cancellable version of WalkSub, WalkSubReverse,
+// WalkSubPreorder, WalkSubPostorder and WalkSubLevelOrder.
+func (t *Tree) WalkSubContext(ctx context.Context, v ErrVisitor, x *Node, o Order) error {
+	it := t.Iter(x, o)
+	return walkContext(ctx, v, it.Next(), func(*Node) *Node { return it.Next() })
+}
+
+func walkContext(ctx context.Context, v ErrVisitor, x *Node, next func(*Node) *Node) error {
+	done := ctx.Done()
+	for i := 0; x != nil; i, x = i+1, next(x) {
+		if done != nil && i%ctxCheckInterval == 0 {
+			select {
+			case <-done:
+				return ctx.Err()
+			default:
+			}
+		}
+		var err error
+		if v, err = v.Visit(x); err != nil || v == nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package rbtree
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkContext(t *testing.T) {
+	n := 1 << 10
+	tr := New(CompareInt)
+	for i := 0; i < n; i++ {
+		tr.Insert(r.Intn(n))
+	}
+
+	walks := map[Order]func(ctx context.Context, v ErrVisitor) error{
+		InOrder:      tr.WalkContext,
+		ReverseOrder: tr.WalkReverseContext,
+		PreOrder:     tr.WalkPreorderContext,
+		PostOrder:    tr.WalkPostorderContext,
+		LevelOrder:   tr.WalkLevelOrderContext,
+	}
+	ctx := context.Background()
+	for o, walk := range walks {
+		var got []*Node
+		err := walk(ctx, ErrVisitFunc(func(x *Node) error {
+			got = append(got, x)
+			return nil
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, refOrder(tr.Root(), o), got)
+
+		// Stop without error.
+		size := 0
+		err = walk(ctx, ErrVisitFunc(func(x *Node) error {
+			if size++; size == 10 {
+				return StopWalk
+			}
+			return nil
+		}))
+		assert.NoError(t, err)
+		assert.Equal(t, 10, size)
+
+		// Errors of the visitor are propagated.
+		fail := errors.New("fail")
+		size = 0
+		err = walk(ctx, ErrVisitFunc(func(x *Node) error {
+			if size++; size == 5 {
+				return fail
+			}
+			return nil
+		}))
+		assert.Equal(t, fail, err)
+		assert.Equal(t, 5, size)
+
+		// Cancellation is noticed within ctxCheckInterval nodes.
+		cctx, cancel := context.WithCancel(ctx)
+		size = 0
+		err = walk(cctx, ErrVisitFunc(func(x *Node) error {
+			if size++; size == 100 {
+				cancel()
+			}
+			return nil
+		}))
+		assert.Equal(t, context.Canceled, err)
+		assert.True(t, size >= 100 && size <= 100+ctxCheckInterval)
+	}
+
+	var got []*Node
+	err := tr.WalkPreorderReverseContext(ctx, ErrVisitFunc(func(x *Node) error {
+		got = append(got, x)
+		return nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, len(refOrder(tr.Root(), PreOrder)), len(got))
+	assert.Equal(t, tr.PreorderLast(), got[0])
+
+	got = got[:0]
+	err = tr.WalkPostorderReverseContext(ctx, ErrVisitFunc(func(x *Node) error {
+		got = append(got, x)
+		return nil
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, tr.Root(), got[0])
+
+	x := tr.Root().Left()
+	got = got[:0]
+	err = tr.WalkSubContext(ctx, ErrVisitFunc(func(y *Node) error {
+		got = append(got, y)
+		return nil
+	}), x, InOrder)
+	assert.NoError(t, err)
+	assert.Equal(t, refOrder(x, InOrder), got)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = tr.WalkContext(cctx, ErrVisitFunc(func(x *Node) error {
+		t.Fatal("visited after cancellation")
+		return nil
+	}))
+	assert.Equal(t, context.Canceled, err)
+}
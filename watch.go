@@ -0,0 +1,171 @@
+package rbtree
+
+import "sync/atomic"
+
+// ChangeKind is the kind of a modification of a tree.
+type ChangeKind int
+
+// Kinds of changes
+const (
+	Inserted ChangeKind = iota // New was inserted
+	Deleted                    // Old was deleted
+	Replaced                   // Old was replaced in place by New
+	Cleared                    // the tree was cleaned, removing Values
+)
+
+var changeNames = [...]string{
+	Inserted: "inserted",
+	Deleted:  "deleted",
+	Replaced: "replaced",
+	Cleared:  "cleared",
+}
+
+func (k ChangeKind) String() string {
+	if k < 0 || int(k) >= len(changeNames) {
+		return "unknown"
+	}
+	return changeNames[k]
+}
+
+// Change describes a modification of a tree.
+type Change struct {
+	Kind   ChangeKind
+	Old    interface{}   // deleted or replaced payload
+	New    interface{}   // inserted or replacing payload
+	Values []interface{} // payloads removed by Clean, in order
+}
+
+// Subscription receives the changes of a tree, either by a callback or
+// from a channel. Notifications are delivered synchronously from the
+// goroutine modifying the tree, after each modification is complete.
+// Every way of modifying a tree is reported: Upsert and Update report
+// insertions and replacements, and rolling back a transaction reports
+// the changes undoing it.
+type Subscription struct {
+	t         *Tree
+	f         func(Change)
+	c         chan Change
+	lo, hi    interface{}
+	cancelled bool
+	dropped   int64 // accessed atomically
+}
+
+// Subscribe calls f with each change of t, until the subscription is
+// cancelled. f must not modify t.
+func (t *Tree) Subscribe(f func(Change)) *Subscription {
+	s := &Subscription{t: t, f: f}
+	t.subscribe(s)
+	return s
+}
+
+// Watch sends each change of t to a channel with a buffer of size n,
+// returned by C. Modifications never block: changes are dropped when the
+// buffer is full, and counted by Dropped.
+func (t *Tree) Watch(n int) *Subscription {
+	s := &Subscription{t: t, c: make(chan Change, n)}
+	t.subscribe(s)
+	return s
+}
+
+func (t *Tree) subscribe(s *Subscription) {
+	// Copy on write, so that callbacks may subscribe or unsubscribe.
+	subs := make([]*Subscription, len(t.subs), len(t.subs)+1)
+	copy(subs, t.subs)
+	t.subs = append(subs, s)
+}
+
+// Range restricts s to changes of payloads in the half-open range
+// [lo, hi), including payloads moved into or out of it by Tree.Rekey.
+// A nil lo or hi leaves the range unbounded on that side. For Clean,
+// only removed payloads in the range are reported, and nothing is
+// reported if there is none. It returns s.
+func (s *Subscription) Range(lo, hi interface{}) *Subscription {
+	s.lo, s.hi = lo, hi
+	return s
+}
+
+// C returns the channel of a subscription created by Watch, or nil.
+func (s *Subscription) C() <-chan Change { return s.c }
+
+// Dropped returns the number of changes dropped because the channel
+// was full.
+// It may be called from any goroutine.
+func (s *Subscription) Dropped() int { return int(atomic.LoadInt64(&s.dropped)) }
+
+// Unsubscribe cancels s. The channel of s, if any, is closed. Like other
+// methods modifying t, it must be called from the goroutine modifying t,
+// which includes callbacks of subscriptions. Unsubscribing twice has
+// no effect.
+func (s *Subscription) Unsubscribe() {
+	if s.cancelled {
+		return
+	}
+	// A notification in progress may still hold s, so it's marked
+	// cancelled to keep it from sending on the closed channel.
+	s.cancelled = true
+	t := s.t
+	for i, x := range t.subs {
+		if x == s {
+			subs := make([]*Subscription, 0, len(t.subs)-1)
+			subs = append(subs, t.subs[:i]...)
+			t.subs = append(subs, t.subs[i+1:]...)
+			if s.c != nil {
+				close(s.c)
+			}
+			return
+		}
+	}
+}
+
+func (s *Subscription) contains(v interface{}) bool {
+	return (s.lo == nil || s.t.compare(v, s.lo) >= 0) &&
+		(s.hi == nil || s.t.compare(v, s.hi) < 0)
+}
+
+func (s *Subscription) send(c Change) {
+	if s.cancelled {
+		return
+	}
+	if s.f != nil {
+		s.f(c)
+		return
+	}
+	select {
+	case s.c <- c:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// notify delivers c to the subscribers interested in it.
+func (t *Tree) notify(c Change) {
+	for _, s := range t.subs {
+		switch {
+		case c.Kind != Deleted && s.contains(c.New),
+			c.Kind != Inserted && s.contains(c.Old):
+			s.send(c)
+		}
+	}
+}
+
+// notifyClean delivers the removal of values, which are in order.
+func (t *Tree) notifyClean(values []interface{}) {
+	for _, s := range t.subs {
+		vs := values
+		if s.lo != nil || s.hi != nil {
+			i := 0
+			for i < len(vs) && !s.contains(vs[i]) {
+				i++
+			}
+			j := i
+			for j < len(vs) && s.contains(vs[j]) {
+				j++
+			}
+			if i == j {
+				continue
+			}
+			vs = vs[i:j:j]
+		}
+		s.send(Change{Kind: Cleared, Values: vs})
+	}
+}
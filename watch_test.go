@@ -0,0 +1,134 @@
+package rbtree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	tr := New(compareInt)
+	var all, ranged []Change
+	s := tr.Subscribe(func(c Change) { all = append(all, c) })
+	tr.Subscribe(func(c Change) { ranged = append(ranged, c) }).Range(3, 6)
+
+	for i := 0; i < 8; i++ {
+		tr.Insert(i)
+	}
+	tr.Insert(0) // no change
+	tr.Replace(tr.Search(4), 4)
+	tr.DeleteValue(5)
+	tr.DeleteValue(5) // no change
+	tr.Delete(tr.Search(1))
+	tr.Clean()
+
+	var want []Change
+	for i := 0; i < 8; i++ {
+		want = append(want, Change{Kind: Inserted, New: i})
+	}
+	want = append(want,
+		Change{Kind: Replaced, Old: 4, New: 4},
+		Change{Kind: Deleted, Old: 5},
+		Change{Kind: Deleted, Old: 1},
+		Change{Kind: Cleared, Values: []interface{}{0, 2, 3, 4, 6, 7}},
+	)
+	assert.Equal(t, want, all)
+	assert.Equal(t, []Change{
+		{Kind: Inserted, New: 3},
+		{Kind: Inserted, New: 4},
+		{Kind: Inserted, New: 5},
+		{Kind: Replaced, Old: 4, New: 4},
+		{Kind: Deleted, Old: 5},
+		{Kind: Cleared, Values: []interface{}{3, 4}},
+	}, ranged)
+
+	s.Unsubscribe()
+	s.Unsubscribe()
+	tr.Insert(9)
+	tr.Clean() // nothing in range for the other subscriber
+	assert.Len(t, all, len(want))
+	assert.Len(t, ranged, 6)
+}
+
+func TestWatch(t *testing.T) {
+	tr := New(compareInt)
+	s := tr.Watch(2)
+	tr.Insert(1)
+	tr.Insert(2)
+	tr.Insert(3)
+	assert.Equal(t, 1, s.Dropped())
+	assert.Equal(t, Change{Kind: Inserted, New: 1}, <-s.C())
+	assert.Equal(t, Change{Kind: Inserted, New: 2}, <-s.C())
+
+	tr.Update(3, func(old interface{}) interface{} { return old })
+	assert.Equal(t, Change{Kind: Replaced, Old: 3, New: 3}, <-s.C())
+
+	s.Unsubscribe()
+	_, ok := <-s.C()
+	assert.False(t, ok)
+	tr.Insert(4) // must not send on the closed channel
+}
+
+func TestSubscribeTx(t *testing.T) {
+	tr := New(compareInt)
+	tr.Insert(1)
+	var changes []Change
+	tr.Subscribe(func(c Change) { changes = append(changes, c) })
+
+	tx := tr.Begin()
+	tx.Insert(2)
+	tx.DeleteValue(1)
+	tx.Rollback()
+	assert.Equal(t, []Change{
+		{Kind: Inserted, New: 2},
+		{Kind: Deleted, Old: 1},
+		{Kind: Inserted, New: 1},
+		{Kind: Deleted, Old: 2},
+	}, changes)
+}
+
+func TestSubscribeRekey(t *testing.T) {
+	tr := New(compareInt)
+	var changes []Change
+	tr.Subscribe(func(c Change) { changes = append(changes, c) }).Range(0, 10)
+	x, _ := tr.Insert(20)
+	tr.Insert(30)
+	tr.Rekey(x, 5)  // moved into the range
+	tr.Rekey(x, 40) // moved out of the range
+	tr.Rekey(x, 50)
+	assert.Equal(t, []Change{
+		{Kind: Replaced, Old: 20, New: 5},
+		{Kind: Replaced, Old: 5, New: 40},
+	}, changes)
+}
+
+func TestUnsubscribeInCallback(t *testing.T) {
+	tr := New(compareInt)
+	n := 0
+	var s *Subscription
+	s = tr.Subscribe(func(Change) {
+		n++
+		s.Unsubscribe()
+	})
+	tr.Subscribe(func(Change) { n++ })
+	tr.Insert(1)
+	tr.Insert(2)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "cleared", Cleared.String())
+}
+
+func TestUnsubscribeWatchInCallback(t *testing.T) {
+	tr := New(compareInt)
+	var w, x *Subscription
+	n := 0
+	tr.Subscribe(func(Change) {
+		w.Unsubscribe()
+		x.Unsubscribe()
+	})
+	w = tr.Watch(4)
+	x = tr.Subscribe(func(Change) { n++ })
+	tr.Insert(1) // must not send on the closed channel
+	_, ok := <-w.C()
+	assert.False(t, ok)
+	assert.Zero(t, n)
+}